@@ -2,8 +2,10 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"flag"
 	"fmt"
+	"io/fs"
 	"log"
 	"os"
 	"strings"
@@ -16,6 +18,11 @@ import (
 	"github.com/yashs662/SynchroDB/pkg/protocol"
 )
 
+const (
+	defaultAddress    = "127.0.0.1:8000"
+	defaultConfigPath = "config/client.yaml"
+)
+
 var (
 	benchmarkPrefix = "synchrodb-benchmark"
 	pingCommand     = protocol.PingCommand{}
@@ -34,20 +41,80 @@ var defaultCommands = []string{
 }
 
 func main() {
-	address := flag.String("address", "127.0.0.1:8000", "Server address")
-	configPath := flag.String("config", "config/server.yaml", "Path to the server config file")
+	address := flag.String("address", defaultAddress, "Server address")
+	configPath := flag.String("config", defaultConfigPath, "Path to the client config file")
+	profileName := flag.String("profile", "", "Profile of the client config to use, its default_profile when empty")
 	benchmark := flag.Bool("benchmark", false, "Benchmark the command")
 	clients := flag.Int("clients", 10, "Number of concurrent clients for benchmarking")
 	iterations := flag.Int("iterations", 1000, "Number of iterations per client for benchmarking")
+	username := flag.String("user", "", "ACL user to authenticate as, the default user when empty")
+	caFile := flag.String("ca-file", "", "PEM bundle of the CAs trusted to sign the server certificate")
+	serverName := flag.String("server-name", "", "Name to verify the server certificate against, the address host by default")
+	certFile := flag.String("cert", "", "Client certificate for servers requiring mutual TLS")
+	keyFile := flag.String("key", "", "Client certificate key for servers requiring mutual TLS")
+	insecure := flag.Bool("insecure", false, "Skip verification of the server certificate")
+	plaintext := flag.Bool("plaintext", false, "Connect without TLS to a plaintext TCP listener")
+	socket := flag.String("socket", "", "Connect to the Unix socket at this path instead of the address")
 
 	flag.Parse()
 
-	cfg, err := config.LoadConfigFromPath(*configPath)
-	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+	setFlags := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { setFlags[f.Name] = true })
+
+	// The profile provides the defaults, flags given on the command line
+	// override them. A missing config file is only an error when the path
+	// was given explicitly.
+	var profile config.ClientProfile
+	cfg, err := config.LoadClientConfigFromPath(*configPath)
+	switch {
+	case err == nil:
+		profile, err = cfg.Profile(*profileName)
+		if err != nil {
+			log.Fatalf("Failed to load client profile: %v", err)
+		}
+	case setFlags["config"] || setFlags["profile"] || !errors.Is(err, fs.ErrNotExist):
+		log.Fatalf("Failed to load client config: %v", err)
+	}
+	overrides := map[string]func(){
+		"address":     func() { profile.Address = *address },
+		"user":        func() { profile.Username = *username },
+		"ca-file":     func() { profile.CAFile = *caFile },
+		"server-name": func() { profile.ServerName = *serverName },
+		"cert":        func() { profile.CertFile = *certFile },
+		"key":         func() { profile.KeyFile = *keyFile },
+		"insecure":    func() { profile.InsecureSkipVerify = *insecure },
+		"plaintext":   func() { profile.Plaintext = *plaintext },
+		"socket":      func() { profile.Socket = *socket },
+	}
+	for name := range setFlags {
+		if override, ok := overrides[name]; ok {
+			override()
+		}
+	}
+	if profile.Address == "" {
+		profile.Address = defaultAddress
+	}
+
+	options := client.Options{
+		Username:    profile.Username,
+		Password:    profile.Password,
+		AuthEnabled: profile.Password != "",
+		Plaintext:   profile.Plaintext,
+		TLS: client.TLSOptions{
+			CAFile:             profile.CAFile,
+			ServerName:         profile.ServerName,
+			CertFile:           profile.CertFile,
+			KeyFile:            profile.KeyFile,
+			InsecureSkipVerify: profile.InsecureSkipVerify,
+		},
+	}
+	*address = profile.Address
+	if profile.Socket != "" {
+		*address = profile.Socket
+		options.Network = "unix"
 	}
 
-	client, err := client.NewClient(*address, cfg.Server.Password, cfg.Server.AuthEnabled)
+	client, err := client.NewClientWithOptions(*address, options)
 	if err != nil {
 		log.Fatalf("Failed to create client: %v", err)
 	}
@@ -1,13 +1,17 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"flag"
 	"fmt"
 	"os"
 	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/yashs662/SynchroDB/internal/auth"
 	"github.com/yashs662/SynchroDB/internal/config"
 	"github.com/yashs662/SynchroDB/internal/logger"
 	"github.com/yashs662/SynchroDB/pkg/database"
@@ -16,17 +20,37 @@ import (
 
 func main() {
 	configPath := flag.String("config", "config/server.yaml", "Path to configuration file")
+	hashPassword := flag.Bool("hash-password", false, "Read a password from stdin, print its argon2id hash for the config and exit")
+	overrides := config.RegisterFlags(flag.CommandLine)
 	flag.Parse()
 
+	if *hashPassword {
+		password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && password == "" {
+			fmt.Println("FATAL: failed to read password: " + err.Error())
+			os.Exit(1)
+		}
+		hash, err := auth.HashPassword(strings.TrimRight(password, "\r\n"))
+		if err != nil {
+			fmt.Println("FATAL: " + err.Error())
+			os.Exit(1)
+		}
+		fmt.Println(hash)
+		return
+	}
+
 	// Load configuration from specified path
-	config, err := config.LoadConfigFromPath(*configPath)
+	config, err := config.Load(*configPath, overrides)
 	if err != nil {
 		fmt.Println("FATAL: " + err.Error())
 		os.Exit(1)
 	}
 
 	// Initialize Logger
-	logger.Init(config)
+	if err := logger.Init(config); err != nil {
+		fmt.Println("FATAL: " + err.Error())
+		os.Exit(1)
+	}
 
 	store := database.NewKVStore()
 	var aofWriter *database.AOFWriter
@@ -35,7 +59,6 @@ func main() {
 		aofWriter, err = database.NewAOFWriter(config.Server.PersistentAOFPath)
 		if err != nil {
 			logger.Fatal("Failed to create AOF writer: " + err.Error())
-			os.Exit(1)
 		}
 	}
 
@@ -49,7 +72,22 @@ func main() {
 	go func() {
 		if err := server.Start(config); err != nil {
 			logger.Fatal("Failed to start the server: " + err.Error())
-			os.Exit(1)
+		}
+	}()
+
+	// Reload the config file and the TLS certificate on SIGHUP
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			if err := server.ReloadConfig(); err != nil {
+				logger.Errorf("Failed to reload the config, keeping the current settings: %v", err)
+			}
+			if err := server.ReloadCertificates(); err != nil {
+				logger.Errorf("Failed to reload TLS certificate: %v", err)
+				continue
+			}
+			logger.Info("Reloaded TLS certificate")
 		}
 	}()
 
@@ -0,0 +1,187 @@
+// Package auth verifies passwords against the bcrypt and argon2id hashes
+// accepted in the server config and ACL file.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Parameters used by HashPassword, following the argon2id recommendations of
+// RFC 9106 for memory constrained environments.
+const (
+	argon2Time    = 3
+	argon2Memory  = 64 * 1024
+	argon2Threads = 4
+	argon2KeyLen  = 32
+	argon2SaltLen = 16
+)
+
+var errInvalidHash = errors.New("invalid password hash")
+
+// verifySlots bounds the hashes verified at once. An argon2id verification
+// takes tens of megabytes, so many concurrent logins, such as HTTP gateway
+// requests each carrying credentials, would otherwise exhaust memory.
+var verifySlots = make(chan struct{}, runtime.GOMAXPROCS(0))
+
+// IsHash reports whether s looks like a bcrypt ("$2a$", "$2b$", "$2y$") or
+// argon2id ("$argon2id$") hash rather than a cleartext password.
+func IsHash(s string) bool {
+	return strings.HasPrefix(s, "$2a$") || strings.HasPrefix(s, "$2b$") ||
+		strings.HasPrefix(s, "$2y$") || strings.HasPrefix(s, "$argon2id$")
+}
+
+// Verify reports whether password matches hash. It waits while too many
+// other hashes are being verified.
+func Verify(hash, password string) (bool, error) {
+	verifySlots <- struct{}{}
+	defer func() { <-verifySlots }()
+	if strings.HasPrefix(hash, "$argon2id$") {
+		return verifyArgon2id(hash, password)
+	}
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+		return false, nil
+	}
+	return false, fmt.Errorf("%w: %v", errInvalidHash, err)
+}
+
+// Validate checks that hash can be used by Verify.
+func Validate(hash string) error {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		_, _, _, err := parseArgon2id(hash)
+		return err
+	}
+	if !IsHash(hash) {
+		return errInvalidHash
+	}
+	if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+		return fmt.Errorf("%w: %v", errInvalidHash, err)
+	}
+	return nil
+}
+
+// HashPassword hashes password with argon2id in the PHC string format:
+// $argon2id$v=19$m=65536,t=3,p=4$<salt>$<key>.
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	key := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, argon2Memory, argon2Time, argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// dummyHashes caches the hashes made by DummyHash, by algorithm and cost.
+var (
+	dummyMu     sync.Mutex
+	dummyHashes = make(map[string]string)
+)
+
+// DummyHash returns the hash of a random password made with the algorithm
+// and cost of hash, so that the passwords of users that do not exist take as
+// long to verify as the ones of users that do. Dummy hashes are made once per
+// algorithm and cost.
+func DummyHash(hash string) (string, error) {
+	var cacheKey string
+	var makeHash func(password []byte) (string, error)
+	if strings.HasPrefix(hash, "$argon2id$") {
+		params, _, key, err := parseArgon2id(hash)
+		if err != nil {
+			return "", err
+		}
+		cacheKey = fmt.Sprintf("argon2id m=%d,t=%d,p=%d,l=%d", params.memory, params.time, params.threads, len(key))
+		makeHash = func(password []byte) (string, error) {
+			salt := make([]byte, argon2SaltLen)
+			if _, err := rand.Read(salt); err != nil {
+				return "", fmt.Errorf("failed to generate salt: %w", err)
+			}
+			derived := argon2.IDKey(password, salt, params.time, params.memory, params.threads, uint32(len(key)))
+			return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, params.memory, params.time, params.threads,
+				base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(derived)), nil
+		}
+	} else {
+		cost, err := bcrypt.Cost([]byte(hash))
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", errInvalidHash, err)
+		}
+		cacheKey = fmt.Sprintf("bcrypt %d", cost)
+		makeHash = func(password []byte) (string, error) {
+			hashed, err := bcrypt.GenerateFromPassword(password, cost)
+			return string(hashed), err
+		}
+	}
+
+	dummyMu.Lock()
+	defer dummyMu.Unlock()
+	if dummy, ok := dummyHashes[cacheKey]; ok {
+		return dummy, nil
+	}
+	password := make([]byte, 32)
+	if _, err := rand.Read(password); err != nil {
+		return "", fmt.Errorf("failed to generate password: %w", err)
+	}
+	// bcrypt only uses the first 72 bytes, and rejects longer passwords
+	dummy, err := makeHash([]byte(base64.RawStdEncoding.EncodeToString(password)))
+	if err != nil {
+		return "", err
+	}
+	dummyHashes[cacheKey] = dummy
+	return dummy, nil
+}
+
+type argon2Params struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+}
+
+func parseArgon2id(hash string) (argon2Params, []byte, []byte, error) {
+	var params argon2Params
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, errInvalidHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("%w: unsupported argon2 version", errInvalidHash)
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return params, nil, nil, fmt.Errorf("%w: %v", errInvalidHash, err)
+	}
+	if params.memory == 0 || params.time == 0 || params.threads == 0 {
+		return params, nil, nil, fmt.Errorf("%w: argon2 parameters must be positive", errInvalidHash)
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("%w: %v", errInvalidHash, err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, fmt.Errorf("%w: invalid key", errInvalidHash)
+	}
+	return params, salt, key, nil
+}
+
+func verifyArgon2id(hash, password string) (bool, error) {
+	params, salt, key, err := parseArgon2id(hash)
+	if err != nil {
+		return false, err
+	}
+	derived := argon2.IDKey([]byte(password), salt, params.time, params.memory, params.threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(derived, key) == 1, nil
+}
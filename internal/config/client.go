@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ClientConfig is the client's own configuration file, holding one or more
+// named connection profiles, so clients never need to read the server config.
+type ClientConfig struct {
+	DefaultProfile string                   `yaml:"default_profile"`
+	Profiles       map[string]ClientProfile `yaml:"profiles"`
+}
+
+// ClientProfile describes how to connect to one server.
+type ClientProfile struct {
+	Address   string `yaml:"address"`
+	Socket    string `yaml:"socket"`
+	Plaintext bool   `yaml:"plaintext"`
+	Username  string `yaml:"username"`
+	// Password may be an environment variable reference such as
+	// ${SYNCHRODB_PASSWORD}, or be read from PasswordFile.
+	Password           string `yaml:"password" json:"-"`
+	PasswordFile       string `yaml:"password_file"`
+	CAFile             string `yaml:"ca_file"`
+	ServerName         string `yaml:"server_name"`
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+func LoadClientConfigFromPath(path string) (*ClientConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client config file: %w", err)
+	}
+	var config ClientConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal client config: %w", err)
+	}
+	return &config, nil
+}
+
+// Profile returns the named profile with its password resolved, or the
+// default profile when name is empty.
+func (c *ClientConfig) Profile(name string) (ClientProfile, error) {
+	if name == "" {
+		name = c.DefaultProfile
+	}
+	if name == "" && len(c.Profiles) == 1 {
+		for only := range c.Profiles {
+			name = only
+		}
+	}
+	profile, ok := c.Profiles[name]
+	if !ok {
+		names := make([]string, 0, len(c.Profiles))
+		for n := range c.Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return ClientProfile{}, fmt.Errorf("unknown profile %q, expected one of: %s", name, strings.Join(names, ", "))
+	}
+	var err error
+	profile.Password, err = ResolveSecret(profile.Password, profile.PasswordFile)
+	if err != nil {
+		return ClientProfile{}, fmt.Errorf("invalid password in profile %q: %w", name, err)
+	}
+	return profile, nil
+}
@@ -3,26 +3,136 @@ package config
 import (
 	"fmt"
 	"os"
-
-	"gopkg.in/yaml.v3"
+	"strings"
 )
 
 type Config struct {
+	// Path is the file the config was loaded from, empty when it was not,
+	// and flags the command line overrides applied to it
+	Path   string `yaml:"-"`
+	flags  *Flags
 	Server struct {
-		Address            string `yaml:"address"`
-		Password           string `yaml:"password"`
+		Address string `yaml:"address"`
+		// Password is the default user's password, in cleartext or as a bcrypt
+		// or argon2id hash. It is never included in the debug log.
+		Password           string `yaml:"password" json:"-"`
+		PasswordFile       string `yaml:"password_file"`
 		AuthEnabled        bool   `yaml:"auth_enabled"`
 		PersistentAOFPath  string `yaml:"persistent_aof_path"`
 		ReplayAOFOnStartup bool   `yaml:"replay_aof_on_startup"`
 		MaxConnections     int    `yaml:"max_connections"`
-		RateLimit          int    `yaml:"rate_limit"`
-		CertFile           string `yaml:"cert_file"`
-		KeyFile            string `yaml:"key_file"`
+		// RateLimit and WriteRateLimit are in commands per second, per client
+		// IP or per user as chosen by RateLimitKey
+		RateLimit           int        `yaml:"rate_limit"`
+		RateLimitBurst      int        `yaml:"rate_limit_burst"`
+		WriteRateLimit      int        `yaml:"write_rate_limit"`
+		WriteRateLimitBurst int        `yaml:"write_rate_limit_burst"`
+		RateLimitKey        string     `yaml:"rate_limit_key"`
+		CertFile            string     `yaml:"cert_file"`
+		KeyFile             string     `yaml:"key_file"`
+		ScriptTimeLimit     int        `yaml:"script_time_limit_ms"`
+		ACLFile             string     `yaml:"acl_file"`
+		ClientCAFile        string     `yaml:"client_ca_file"`
+		ClientAuth          string     `yaml:"client_auth"`
+		ClientCertUser      bool       `yaml:"client_cert_user"`
+		MinTLSVersion       string     `yaml:"min_tls_version"`
+		CipherSuites        []string   `yaml:"cipher_suites"`
+		ALPNProtocols       []string   `yaml:"alpn_protocols"`
+		Listeners           []Listener `yaml:"listeners"`
+		// HTTPListeners serve the HTTP gateway, disabled when empty
+		HTTPListeners []Listener `yaml:"http_listeners"`
+		// SlowlogThreshold is in microseconds, negative disables the slow log
+		SlowlogThreshold int `yaml:"slowlog_threshold_us"`
+		SlowlogMaxLen    int `yaml:"slowlog_max_len"`
+		// LatencyMonitorThreshold is in milliseconds, 0 disables the monitor
+		LatencyMonitorThreshold int `yaml:"latency_monitor_threshold_ms"`
+		// MetricsAddress is where /metrics is served for Prometheus over plain
+		// HTTP, disabled when empty
+		MetricsAddress string `yaml:"metrics_address"`
+		// AUTH brute-force protection, see the README for the defaults
+		AuthMaxAttemptsPerConn int `yaml:"auth_max_attempts_per_conn"`
+		AuthBackoffBase        int `yaml:"auth_backoff_base_ms"`
+		AuthBackoffMax         int `yaml:"auth_backoff_max_ms"`
+		AuthBanAfterFailures   int `yaml:"auth_ban_after_failures"`
+		AuthBanDuration        int `yaml:"auth_ban_duration_s"`
 	} `yaml:"server"`
 	Log struct {
-		File  string `yaml:"file"`
-		Debug bool   `yaml:"debug"`
+		File string `yaml:"file"`
+		// Debug sets the level to debug, overriding Level
+		Debug bool `yaml:"debug"`
+		// Level is debug, info, warn, error or fatal, info by default
+		Level string `yaml:"level"`
+		// Format is text, json or logfmt, text by default
+		Format string `yaml:"format"`
+		// Rotation of the log file
+		MaxSize    int   `yaml:"max_size_mb"`
+		MaxBackups int   `yaml:"max_backups"`
+		MaxAge     int   `yaml:"max_age_days"`
+		Compress   *bool `yaml:"compress"`
 	} `yaml:"log"`
+	Audit struct {
+		// File is the audit log, appended to as JSON lines, disabled when
+		// empty
+		File string `yaml:"file"`
+	} `yaml:"audit"`
+}
+
+// Listener is an additional listener served next to the TLS listener on
+// Server.Address, or a listener of the HTTP gateway. Type is "tls", "tcp"
+// for plaintext TCP restricted to loopback addresses, or "unix" for a Unix
+// domain socket, in which case Address is the socket path and SocketMode its
+// octal file permissions.
+type Listener struct {
+	Type       string `yaml:"type"`
+	Address    string `yaml:"address"`
+	SocketMode string `yaml:"socket_mode"`
+}
+
+// Defaults of the settings, which apply when a setting is neither in the
+// config file nor overridden. The other settings default to their zero value:
+// disabled, unlimited or empty.
+const (
+	DefaultSlowlogThreshold       = 10000 // microseconds
+	DefaultSlowlogMaxLen          = 128
+	DefaultRateLimitKey           = "ip"
+	DefaultScriptTimeLimit        = 5000 // milliseconds
+	DefaultMinTLSVersion          = "1.2"
+	DefaultAuthMaxAttemptsPerConn = 5
+	DefaultAuthBackoffBase        = 100   // milliseconds
+	DefaultAuthBackoffMax         = 30000 // milliseconds
+	DefaultAuthBanAfterFailures   = 20
+	DefaultAuthBanDuration        = 900 // seconds
+	DefaultLogFile                = "synchrodb.log"
+	DefaultLogLevel               = "info"
+	DefaultLogFormat              = "text"
+	DefaultLogMaxSize             = 10 // megabytes
+	DefaultLogMaxBackups          = 3
+	DefaultLogMaxAge              = 28 // days
+)
+
+// Default returns the config used before the config file, the environment
+// and the command line are applied.
+func Default() *Config {
+	config := &Config{}
+	config.Server.SlowlogThreshold = DefaultSlowlogThreshold
+	config.Server.SlowlogMaxLen = DefaultSlowlogMaxLen
+	config.Server.RateLimitKey = DefaultRateLimitKey
+	config.Server.ScriptTimeLimit = DefaultScriptTimeLimit
+	config.Server.MinTLSVersion = DefaultMinTLSVersion
+	config.Server.AuthMaxAttemptsPerConn = DefaultAuthMaxAttemptsPerConn
+	config.Server.AuthBackoffBase = DefaultAuthBackoffBase
+	config.Server.AuthBackoffMax = DefaultAuthBackoffMax
+	config.Server.AuthBanAfterFailures = DefaultAuthBanAfterFailures
+	config.Server.AuthBanDuration = DefaultAuthBanDuration
+	config.Log.File = DefaultLogFile
+	config.Log.Level = DefaultLogLevel
+	config.Log.Format = DefaultLogFormat
+	config.Log.MaxSize = DefaultLogMaxSize
+	config.Log.MaxBackups = DefaultLogMaxBackups
+	config.Log.MaxAge = DefaultLogMaxAge
+	compress := true
+	config.Log.Compress = &compress
+	return config
 }
 
 func LoadConfig() (*Config, error) {
@@ -30,13 +140,69 @@ func LoadConfig() (*Config, error) {
 }
 
 func LoadConfigFromPath(path string) (*Config, error) {
+	return Load(path, nil)
+}
+
+// Load reads the config file at path over the defaults, then applies the
+// SYNCHRODB_* environment variables and the command line flags, when flags
+// is not nil, and validates the result. Unknown keys in the file are errors,
+// and every invalid setting is reported at once.
+func Load(path string, flags *Flags) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
-	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+	config := Default()
+	if err := decodeStrict(data, config); err != nil {
+		return nil, err
+	}
+	if err := applyEnv(config); err != nil {
+		return nil, err
+	}
+	if err := flags.apply(config); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file %s:\n%w", path, err)
+	}
+	config.Path = path
+	config.flags = flags
+	config.Server.Password, err = ResolveSecret(config.Server.Password, config.Server.PasswordFile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid password: %w", err)
+	}
+	return config, nil
+}
+
+// Reload loads the config again from the same file, environment and flags.
+func (c *Config) Reload() (*Config, error) {
+	if c.Path == "" {
+		return nil, fmt.Errorf("the config was not loaded from a file")
+	}
+	return Load(c.Path, c.flags)
+}
+
+// ResolveSecret returns a secret given either inline or in a file. Inline
+// values of the form ${NAME} are read from the environment variable NAME, and
+// files hold the secret on their own, surrounding whitespace is ignored.
+func ResolveSecret(value, file string) (string, error) {
+	if file != "" {
+		if value != "" {
+			return "", fmt.Errorf("set either the secret or its file, not both")
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	if strings.HasPrefix(value, "${") && strings.HasSuffix(value, "}") {
+		name := value[2 : len(value)-1]
+		secret, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return secret, nil
 	}
-	return &config, nil
+	return value, nil
 }
@@ -0,0 +1,210 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix starts the names of the environment variables overriding
+// settings, as in SYNCHRODB_SERVER_RATE_LIMIT for server.rate_limit.
+const EnvPrefix = "SYNCHRODB_"
+
+// setting is a leaf of the config, named by its dotted path of YAML keys.
+type setting struct {
+	path  string
+	value reflect.Value
+}
+
+// settings returns every setting of config in declaration order.
+func settings(config *Config) []setting {
+	return appendSettings(nil, "", reflect.ValueOf(config).Elem())
+}
+
+func appendSettings(list []setting, prefix string, v reflect.Value) []setting {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		name := yamlName(field)
+		if name == "" {
+			continue
+		}
+		if field.Type.Kind() == reflect.Struct {
+			list = appendSettings(list, prefix+name+".", v.Field(i))
+			continue
+		}
+		list = append(list, setting{path: prefix + name, value: v.Field(i)})
+	}
+	return list
+}
+
+// yamlName returns the key of field in the config file, empty for fields
+// that are not read from it.
+func yamlName(field reflect.StructField) string {
+	if !field.IsExported() {
+		return ""
+	}
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// EnvName returns the environment variable overriding the setting at path.
+func EnvName(path string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(path, ".", "_"))
+}
+
+// set parses value into the setting. Lists of strings are comma separated,
+// and other lists, such as listeners, are given in YAML flow style.
+func (s setting) set(value string) error {
+	switch {
+	case s.value.Kind() == reflect.String:
+		s.value.SetString(value)
+		return nil
+	case s.value.Type() == reflect.TypeOf([]string(nil)) && !strings.HasPrefix(strings.TrimSpace(value), "["):
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		s.value.Set(reflect.ValueOf(list))
+		return nil
+	}
+	parsed := reflect.New(s.value.Type())
+	if err := yaml.Unmarshal([]byte(value), parsed.Interface()); err != nil {
+		return fmt.Errorf("expected a %s", typeName(s.value.Type()))
+	}
+	s.value.Set(parsed.Elem())
+	return nil
+}
+
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int:
+		return "number"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Ptr:
+		return typeName(t.Elem())
+	}
+	return "list in YAML flow style"
+}
+
+// applyEnv overrides the settings of config with the SYNCHRODB_* variables
+// that are set.
+func applyEnv(config *Config) error {
+	var errs []error
+	for _, s := range settings(config) {
+		name := EnvName(s.path)
+		if value, ok := os.LookupEnv(name); ok {
+			if err := s.set(value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %v", name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Flags are the command line flags overriding settings, one per setting and
+// named by its path, as in -server.rate_limit.
+type Flags struct {
+	values map[string]string
+}
+
+// RegisterFlags defines a flag for every setting on fs.
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	flags := &Flags{values: make(map[string]string)}
+	for _, s := range settings(&Config{}) {
+		path := s.path
+		fs.Func(path, fmt.Sprintf("override %s of the config file, also set by %s", path, EnvName(path)), func(value string) error {
+			flags.values[path] = value
+			return nil
+		})
+	}
+	return flags
+}
+
+// apply overrides the settings of config with the flags given on the command
+// line. It does nothing on nil flags.
+func (f *Flags) apply(config *Config) error {
+	if f == nil {
+		return nil
+	}
+	var errs []error
+	for _, s := range settings(config) {
+		if value, ok := f.values[s.path]; ok {
+			if err := s.set(value); err != nil {
+				errs = append(errs, fmt.Errorf("-%s: %v", s.path, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// decodeStrict decodes the config file data into config, failing on keys
+// that match no setting. Every unknown key is reported with its line.
+func decodeStrict(data []byte, config *Config) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if errs := unknownKeys(&doc, reflect.TypeOf(config).Elem(), ""); len(errs) > 0 {
+		return fmt.Errorf("invalid config file:\n%w", errors.Join(errs...))
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+	return nil
+}
+
+// unknownKeys returns an error for every key below node that is not a field
+// of t, prefixing their paths with prefix.
+func unknownKeys(node *yaml.Node, t reflect.Type, prefix string) []error {
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			return nil
+		}
+		return unknownKeys(node.Content[0], t, prefix)
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	var errs []error
+	switch {
+	case t.Kind() == reflect.Struct && node.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			field, ok := fieldByYAMLName(t, key.Value)
+			if !ok {
+				errs = append(errs, fmt.Errorf("line %d: unknown setting %s%s", key.Line, prefix, key.Value))
+				continue
+			}
+			errs = append(errs, unknownKeys(node.Content[i+1], field.Type, prefix+key.Value+".")...)
+		}
+	case t.Kind() == reflect.Slice && node.Kind == yaml.SequenceNode:
+		for i, item := range node.Content {
+			errs = append(errs, unknownKeys(item, t.Elem(), fmt.Sprintf("%s[%d].", strings.TrimSuffix(prefix, "."), i))...)
+		}
+	}
+	return errs
+}
+
+func fieldByYAMLName(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		if field := t.Field(i); yamlName(field) == name {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
@@ -0,0 +1,174 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rewrite sets keys of the YAML file at path to values and deletes the keys
+// in remove, keeping the comments and order of everything else. Keys are
+// dotted paths such as "server.rate_limit" and are appended to their section
+// when missing. The file is replaced atomically.
+func Rewrite(path string, values map[string]interface{}, remove []string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("config file %s is not a mapping", path)
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		var value yaml.Node
+		if err := value.Encode(values[key]); err != nil {
+			return fmt.Errorf("failed to encode %s: %w", key, err)
+		}
+		if err := setKey(root, strings.Split(key, "."), &value); err != nil {
+			return fmt.Errorf("failed to set %s: %w", key, err)
+		}
+	}
+	for _, key := range remove {
+		removeKey(root, strings.Split(key, "."))
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return fmt.Errorf("failed to encode config file: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("failed to encode config file: %w", err)
+	}
+	return writeFileAtomic(path, buf.Bytes())
+}
+
+// setKey sets the key at path below mapping to value, creating the missing
+// mappings on the way.
+func setKey(mapping *yaml.Node, path []string, value *yaml.Node) error {
+	for i := 0; i < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value != path[0] {
+			continue
+		}
+		node := mapping.Content[i+1]
+		if len(path) > 1 {
+			if node.Kind != yaml.MappingNode {
+				return fmt.Errorf("%s is not a mapping", path[0])
+			}
+			return setKey(node, path[1:], value)
+		}
+		// Replace the value in place to keep its comments, and the quoting
+		// of strings
+		style := value.Style
+		if node.Tag == "!!str" && value.Tag == "!!str" {
+			style = node.Style
+		}
+		node.Kind, node.Tag, node.Style, node.Value, node.Content = value.Kind, value.Tag, style, value.Value, value.Content
+		return nil
+	}
+
+	key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: path[0]}
+	if len(path) > 1 {
+		section := &yaml.Node{Kind: yaml.MappingNode}
+		mapping.Content = append(mapping.Content, key, section)
+		return setKey(section, path[1:], value)
+	}
+	// New strings are quoted like the ones of the sample config
+	if value.Kind == yaml.ScalarNode && value.Tag == "!!str" {
+		value.Style = yaml.DoubleQuotedStyle
+	}
+	mapping.Content = append(mapping.Content, key, value)
+	return nil
+}
+
+// removeKey deletes the key at path below mapping. Its comments, such as
+// commented out settings following it, are moved to the next key, or to the
+// previous value when it was the last one.
+func removeKey(mapping *yaml.Node, path []string) {
+	for i := 0; i < len(mapping.Content); i += 2 {
+		key, value := mapping.Content[i], mapping.Content[i+1]
+		if key.Value != path[0] {
+			continue
+		}
+		if len(path) > 1 {
+			if value.Kind == yaml.MappingNode {
+				removeKey(value, path[1:])
+			}
+			return
+		}
+		comments := joinComments(key.HeadComment, key.FootComment, value.FootComment)
+		switch {
+		case i+2 < len(mapping.Content):
+			next := mapping.Content[i+2]
+			next.HeadComment = joinComments(comments, next.HeadComment)
+		case i > 0:
+			previous := mapping.Content[i-1]
+			previous.FootComment = joinComments(previous.FootComment, comments)
+		default:
+			mapping.FootComment = joinComments(comments, mapping.FootComment)
+		}
+		mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+		return
+	}
+}
+
+func joinComments(comments ...string) string {
+	var nonEmpty []string
+	for _, comment := range comments {
+		if comment != "" {
+			nonEmpty = append(nonEmpty, comment)
+		}
+	}
+	return strings.Join(nonEmpty, "\n")
+}
+
+// writeFileAtomic replaces the file at path with data, keeping its
+// permissions, so that a crash never leaves it half written.
+func writeFileAtomic(path string, data []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to stat config file: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary config file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to set config file permissions: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace config file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// validator collects the problems of a config, so that they are reported
+// together.
+type validator struct {
+	errs []error
+}
+
+func (v *validator) fail(path, format string, args ...interface{}) {
+	v.errs = append(v.errs, fmt.Errorf("%s: %s", path, fmt.Sprintf(format, args...)))
+}
+
+func (v *validator) nonNegative(path string, value int) {
+	if value < 0 {
+		v.fail(path, "must not be negative, got %d", value)
+	}
+}
+
+func (v *validator) oneOf(path, value string, allowed ...string) {
+	var names []string
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+		if a != "" {
+			names = append(names, a)
+		}
+	}
+	v.fail(path, "invalid value %q, expected %s", value, strings.Join(names, ", "))
+}
+
+// address checks a host:port address, the host may be empty.
+func (v *validator) address(path, address string) {
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		v.fail(path, "invalid address %q, expected host:port", address)
+		return
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		v.fail(path, "invalid port %q", port)
+	}
+}
+
+// listener checks a listener, reporting whether it uses TLS.
+func (v *validator) listener(path string, listener Listener) bool {
+	switch listener.Type {
+	case "", "tls":
+		v.address(path+".address", listener.Address)
+		return true
+	case "tcp":
+		v.address(path+".address", listener.Address)
+	case "unix":
+		if listener.Address == "" {
+			v.fail(path+".address", "the socket path is required")
+		}
+		if listener.SocketMode != "" {
+			if _, err := strconv.ParseUint(listener.SocketMode, 8, 32); err != nil {
+				v.fail(path+".socket_mode", "invalid value %q, expected octal permissions such as 0660", listener.SocketMode)
+			}
+		}
+	default:
+		v.fail(path+".type", "invalid value %q, expected tls, tcp or unix", listener.Type)
+	}
+	return false
+}
+
+// inputFile checks that a file read by the server exists.
+func (v *validator) inputFile(path, file string) {
+	if file == "" {
+		return
+	}
+	if info, err := os.Stat(file); err != nil {
+		v.fail(path, "cannot read %s: %v", file, errors.Unwrap(err))
+	} else if info.IsDir() {
+		v.fail(path, "%s is a directory", file)
+	}
+}
+
+// outputFile checks that the directory of a file written by the server
+// exists.
+func (v *validator) outputFile(path, file string) {
+	if file == "" {
+		return
+	}
+	dir := filepath.Dir(file)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		v.fail(path, "directory %s of %s does not exist", dir, file)
+	}
+}
+
+// Validate checks every setting, returning one error listing all the invalid
+// ones, one per line.
+func (c *Config) Validate() error {
+	v := &validator{}
+	server := &c.Server
+
+	usesTLS := server.Address != ""
+	if server.Address != "" {
+		v.address("server.address", server.Address)
+	}
+	for i, listener := range server.Listeners {
+		if v.listener(fmt.Sprintf("server.listeners[%d]", i), listener) {
+			usesTLS = true
+		}
+	}
+	for i, listener := range server.HTTPListeners {
+		if v.listener(fmt.Sprintf("server.http_listeners[%d]", i), listener) {
+			usesTLS = true
+		}
+	}
+	if server.Address == "" && len(server.Listeners) == 0 {
+		v.fail("server.address", "no listener, set server.address or server.listeners")
+	}
+	if usesTLS {
+		if server.CertFile == "" {
+			v.fail("server.cert_file", "required by the TLS listener")
+		}
+		if server.KeyFile == "" {
+			v.fail("server.key_file", "required by the TLS listener")
+		}
+	}
+	if server.MetricsAddress != "" {
+		v.address("server.metrics_address", server.MetricsAddress)
+	}
+
+	v.inputFile("server.cert_file", server.CertFile)
+	v.inputFile("server.key_file", server.KeyFile)
+	v.inputFile("server.client_ca_file", server.ClientCAFile)
+	v.inputFile("server.acl_file", server.ACLFile)
+	v.inputFile("server.password_file", server.PasswordFile)
+	v.outputFile("server.persistent_aof_path", server.PersistentAOFPath)
+	v.outputFile("log.file", c.Log.File)
+	v.outputFile("audit.file", c.Audit.File)
+
+	v.nonNegative("server.max_connections", server.MaxConnections)
+	v.nonNegative("server.rate_limit", server.RateLimit)
+	v.nonNegative("server.rate_limit_burst", server.RateLimitBurst)
+	v.nonNegative("server.write_rate_limit", server.WriteRateLimit)
+	v.nonNegative("server.write_rate_limit_burst", server.WriteRateLimitBurst)
+	v.nonNegative("server.script_time_limit_ms", server.ScriptTimeLimit)
+	v.nonNegative("server.slowlog_max_len", server.SlowlogMaxLen)
+	v.nonNegative("server.latency_monitor_threshold_ms", server.LatencyMonitorThreshold)
+	v.nonNegative("server.auth_max_attempts_per_conn", server.AuthMaxAttemptsPerConn)
+	v.nonNegative("server.auth_backoff_base_ms", server.AuthBackoffBase)
+	v.nonNegative("server.auth_backoff_max_ms", server.AuthBackoffMax)
+	v.nonNegative("server.auth_ban_after_failures", server.AuthBanAfterFailures)
+	v.nonNegative("server.auth_ban_duration_s", server.AuthBanDuration)
+	v.nonNegative("log.max_size_mb", c.Log.MaxSize)
+	v.nonNegative("log.max_backups", c.Log.MaxBackups)
+	v.nonNegative("log.max_age_days", c.Log.MaxAge)
+
+	v.oneOf("server.rate_limit_key", server.RateLimitKey, "", "ip", "user")
+	v.oneOf("server.client_auth", server.ClientAuth, "", "none", "optional", "require")
+	v.oneOf("server.min_tls_version", server.MinTLSVersion, "", "1.2", "1.3")
+	v.oneOf("log.level", strings.ToLower(c.Log.Level), "", "debug", "info", "warn", "warning", "error", "fatal")
+	v.oneOf("log.format", strings.ToLower(c.Log.Format), "", "text", "json", "logfmt")
+	if server.Password != "" && server.PasswordFile != "" {
+		v.fail("server.password", "set either the password or password_file, not both")
+	}
+	return errors.Join(v.errs...)
+}
@@ -0,0 +1,127 @@
+// Package latency records latency spikes of server events, such as AOF writes
+// or the expiration cycle, for the LATENCY command. Only events lasting at
+// least the threshold are recorded, and nothing is recorded while the
+// threshold is zero.
+package latency
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Events recorded by the server.
+const (
+	EventCommand     = "command"
+	EventFastCommand = "fast-command"
+	EventAOFWrite    = "aof-write"
+	EventExpireCycle = "expire-cycle"
+)
+
+// HistoryLen is how many samples are kept per event.
+const HistoryLen = 160
+
+// Sample is the worst latency of an event within one second.
+type Sample struct {
+	Time    time.Time
+	Latency time.Duration
+}
+
+// Stats describe the spikes of one event.
+type Stats struct {
+	Event  string
+	Latest Sample
+	Max    time.Duration
+}
+
+type history struct {
+	samples []Sample // oldest first
+	max     time.Duration
+}
+
+var (
+	threshold atomic.Int64 // time.Duration
+
+	mu     sync.Mutex
+	events = make(map[string]*history)
+)
+
+// SetThreshold sets the minimum latency recorded, zero disables the monitor.
+func SetThreshold(d time.Duration) {
+	threshold.Store(int64(d))
+}
+
+func Threshold() time.Duration {
+	return time.Duration(threshold.Load())
+}
+
+// Record records that event took d, when d reaches the threshold. Spikes of
+// the same event within one second are merged, keeping the worst.
+func Record(event string, d time.Duration) {
+	t := Threshold()
+	if t <= 0 || d < t {
+		return
+	}
+	now := time.Now().Truncate(time.Second)
+
+	mu.Lock()
+	defer mu.Unlock()
+	h, ok := events[event]
+	if !ok {
+		h = &history{}
+		events[event] = h
+	}
+	h.max = max(h.max, d)
+	if n := len(h.samples); n > 0 && h.samples[n-1].Time.Equal(now) {
+		h.samples[n-1].Latency = max(h.samples[n-1].Latency, d)
+		return
+	}
+	h.samples = append(h.samples, Sample{Time: now, Latency: d})
+	if len(h.samples) > HistoryLen {
+		h.samples = h.samples[len(h.samples)-HistoryLen:]
+	}
+}
+
+// Latest returns the latest and worst spike of every event, sorted by event.
+func Latest() []Stats {
+	mu.Lock()
+	defer mu.Unlock()
+	stats := make([]Stats, 0, len(events))
+	for event, h := range events {
+		stats = append(stats, Stats{Event: event, Latest: h.samples[len(h.samples)-1], Max: h.max})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Event < stats[j].Event })
+	return stats
+}
+
+// History returns the samples of event, oldest first.
+func History(event string) []Sample {
+	mu.Lock()
+	defer mu.Unlock()
+	h, ok := events[event]
+	if !ok {
+		return nil
+	}
+	return append([]Sample(nil), h.samples...)
+}
+
+// Reset drops the samples of the given events, or of every event when none
+// are given, and returns how many events were dropped.
+func Reset(names ...string) int {
+	mu.Lock()
+	defer mu.Unlock()
+	if len(names) == 0 {
+		n := len(events)
+		clear(events)
+		return n
+	}
+	n := 0
+	for _, name := range names {
+		if _, ok := events[name]; ok {
+			delete(events, name)
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,129 @@
+package logger
+
+import (
+	"context"
+	"time"
+)
+
+// Field is a typed key and value attached to a log entry.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+func String(key, value string) Field {
+	return Field{Key: key, Value: value}
+}
+
+func Int(key string, value int) Field {
+	return Field{Key: key, Value: value}
+}
+
+func Int64(key string, value int64) Field {
+	return Field{Key: key, Value: value}
+}
+
+func Bool(key string, value bool) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Duration logs d as in "1.5ms".
+func Duration(key string, d time.Duration) Field {
+	return Field{Key: key, Value: d.String()}
+}
+
+// Err logs err under the "error" key.
+func Err(err error) Field {
+	if err == nil {
+		return Field{Key: "error", Value: nil}
+	}
+	return Field{Key: "error", Value: err.Error()}
+}
+
+func Any(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Fields shared by the logs of connections and commands, so that every entry
+// about a client can be found by the same keys.
+
+func ClientAddr(addr string) Field {
+	return String("client_addr", addr)
+}
+
+func Command(name string) Field {
+	return String("command", name)
+}
+
+func User(name string) Field {
+	return String("user", name)
+}
+
+func RequestID(id string) Field {
+	return String("request_id", id)
+}
+
+// Logger logs entries carrying its fields on top of the ones given to each
+// call. Loggers are created by With and are safe for concurrent use.
+type Logger struct {
+	fields []Field
+}
+
+// root logs entries without extra fields, it backs the package functions.
+var root = &Logger{}
+
+// With returns a logger adding fields to every entry.
+func With(fields ...Field) *Logger {
+	return root.With(fields...)
+}
+
+func (l *Logger) With(fields ...Field) *Logger {
+	combined := make([]Field, 0, len(l.fields)+len(fields))
+	return &Logger{fields: append(append(combined, l.fields...), fields...)}
+}
+
+func (l *Logger) Debug(message string, fields ...Field) {
+	l.log(LevelDebug, message, fields)
+}
+
+func (l *Logger) Info(message string, fields ...Field) {
+	l.log(LevelInfo, message, fields)
+}
+
+func (l *Logger) Warn(message string, fields ...Field) {
+	l.log(LevelWarn, message, fields)
+}
+
+func (l *Logger) Error(message string, fields ...Field) {
+	l.log(LevelError, message, fields)
+}
+
+func (l *Logger) log(level Level, message string, fields []Field) {
+	if !Enabled(level) {
+		return
+	}
+	if len(l.fields) > 0 {
+		fields = l.With(fields...).fields
+	}
+	dispatch(logEntry{time: time.Now(), level: level, message: message, fields: fields})
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying l, for FromContext.
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the logger carried by ctx, or one without fields.
+func FromContext(ctx context.Context) *Logger {
+	if l, ok := ctx.Value(contextKey{}).(*Logger); ok {
+		return l
+	}
+	return root
+}
+
+// WithRequestID returns a copy of ctx whose logger adds the request ID id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return NewContext(ctx, FromContext(ctx).With(RequestID(id)))
+}
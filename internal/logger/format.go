@@ -0,0 +1,121 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format is how entries are written, to both the console and the file.
+type Format string
+
+const (
+	// FormatText writes "LEVEL: date time message key=value ...", colored on
+	// the console
+	FormatText Format = "text"
+	// FormatJSON writes one JSON object per line
+	FormatJSON Format = "json"
+	// FormatLogfmt writes "time=... level=... msg=... key=value ..."
+	FormatLogfmt Format = "logfmt"
+)
+
+// ParseFormat returns the format called name, text when name is empty.
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(strings.ToLower(name)); format {
+	case "":
+		return FormatText, nil
+	case FormatText, FormatJSON, FormatLogfmt:
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown log format '%s', expected text, json or logfmt", name)
+}
+
+var levelColors = [...]string{
+	LevelDebug: Blue,
+	LevelInfo:  Green,
+	LevelWarn:  Yellow,
+	LevelError: Red,
+	LevelFatal: Red,
+}
+
+// format returns entry as a line, with colors when color is set and the
+// format is text.
+func (f Format) format(entry logEntry, color bool) string {
+	var b strings.Builder
+	switch f {
+	case FormatJSON:
+		b.WriteString(`{"time":`)
+		b.WriteString(strconv.Quote(entry.time.Format(time.RFC3339Nano)))
+		b.WriteString(`,"level":`)
+		b.WriteString(strconv.Quote(entry.level.String()))
+		b.WriteString(`,"msg":`)
+		b.WriteString(jsonValue(entry.message))
+		for _, field := range entry.fields {
+			b.WriteString(",")
+			b.WriteString(jsonValue(field.Key))
+			b.WriteString(":")
+			b.WriteString(jsonValue(field.Value))
+		}
+		b.WriteString("}")
+	case FormatLogfmt:
+		b.WriteString("time=")
+		b.WriteString(entry.time.Format(time.RFC3339Nano))
+		b.WriteString(" level=")
+		b.WriteString(entry.level.String())
+		b.WriteString(" msg=")
+		b.WriteString(logfmtValue(entry.message))
+		writeLogfmtFields(&b, entry.fields)
+	default:
+		prefix := fmt.Sprintf("%-7s", strings.ToUpper(entry.level.String())+":")
+		if color {
+			prefix = levelColors[entry.level] + prefix + Reset
+		}
+		b.WriteString(prefix)
+		layout := "2006/01/02 15:04:05"
+		if Enabled(LevelDebug) {
+			layout += ".000000"
+		}
+		b.WriteString(entry.time.Format(layout))
+		b.WriteString(" ")
+		b.WriteString(entry.message)
+		writeLogfmtFields(&b, entry.fields)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+func writeLogfmtFields(b *strings.Builder, fields []Field) {
+	for _, field := range fields {
+		b.WriteString(" ")
+		b.WriteString(field.Key)
+		b.WriteString("=")
+		b.WriteString(logfmtValue(field.Value))
+	}
+}
+
+// jsonValue encodes value, falling back to its printed form when it cannot
+// be marshalled.
+func jsonValue(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return strconv.Quote(fmt.Sprint(value))
+	}
+	return string(data)
+}
+
+// logfmtValue prints value, quoted when it is empty or holds spaces, quotes,
+// equal signs or control characters.
+func logfmtValue(value interface{}) string {
+	if value == nil {
+		return "nil"
+	}
+	s := fmt.Sprint(value)
+	if s == "" || strings.IndexFunc(s, func(r rune) bool {
+		return r <= ' ' || r == '=' || r == '"' || r == 0x7f
+	}) >= 0 {
+		return strconv.Quote(s)
+	}
+	return s
+}
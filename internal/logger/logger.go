@@ -4,11 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"io"
 	"os"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/yashs662/SynchroDB/internal/config"
+	"github.com/yashs662/SynchroDB/internal/metrics"
 	"gopkg.in/natefinch/lumberjack.v2"
 )
 
@@ -20,164 +24,300 @@ const (
 	Blue   = "\033[34m"
 )
 
+// Defaults of the log file rotation.
+const (
+	defaultMaxSize    = config.DefaultLogMaxSize
+	defaultMaxBackups = config.DefaultLogMaxBackups
+	defaultMaxAge     = config.DefaultLogMaxAge
+)
+
+// Level is the severity of an entry, entries below the current level are
+// dropped.
+type Level int32
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+	LevelFatal
+)
+
+var levelNames = [...]string{"debug", "info", "warn", "error", "fatal"}
+
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelFatal {
+		return fmt.Sprintf("level(%d)", l)
+	}
+	return levelNames[l]
+}
+
+// ParseLevel returns the level called name, as in "debug" or "WARN".
+func ParseLevel(name string) (Level, error) {
+	for i, levelName := range levelNames {
+		if strings.EqualFold(name, levelName) {
+			return Level(i), nil
+		}
+	}
+	if strings.EqualFold(name, "warning") {
+		return LevelWarn, nil
+	}
+	return LevelInfo, fmt.Errorf("unknown log level '%s', expected debug, info, warn, error or fatal", name)
+}
+
+// queueSize is how many entries may wait to be written before new ones are
+// dropped.
+const queueSize = 1000
+
 var (
-	consoleInfoLogger  *log.Logger
-	consoleWarnLogger  *log.Logger
-	consoleErrorLogger *log.Logger
-	consoleFatalLogger *log.Logger
-	consoleDebugLogger *log.Logger
-
-	fileInfoLogger  *log.Logger
-	fileWarnLogger  *log.Logger
-	fileErrorLogger *log.Logger
-	fileFatalLogger *log.Logger
-	fileDebugLogger *log.Logger
-
-	debugMode bool
-	logQueue  chan logEntry
-	wg        sync.WaitGroup
+	level        atomic.Int32 // Level
+	outputFormat = FormatText
+
+	// writeMu serializes writes to the sinks
+	writeMu       sync.Mutex
+	consoleOutput io.Writer = os.Stdout
+	consoleErrors io.Writer = os.Stderr
+	fileOutput    io.Writer = io.Discard
+	logFile       *lumberjack.Logger
+
+	// queue holds the entries waiting for processLogQueue. It is nil before
+	// Init and after Close, entries are then written by the caller.
+	queueMu sync.RWMutex
+	queue   chan logEntry
+	wg      sync.WaitGroup
+	// dropped counts the entries dropped since the last report
+	dropped atomic.Int64
+
+	droppedEntries = metrics.NewCounter("synchrodb_log_entries_dropped_total", "Log entries dropped because the log queue was full")
 )
 
+func init() {
+	SetLevel(LevelInfo)
+}
+
 type logEntry struct {
-	level   string
+	time    time.Time
+	level   Level
 	message string
+	fields  []Field
 }
 
-func Init(cfg *config.Config) {
-	debugMode = cfg.Log.Debug
-	initializeLoggers(cfg.Log.File)
-	logQueue = make(chan logEntry, 1000)
+// Init starts logging as set in the log section of cfg. The level is debug
+// when log.debug is set, and otherwise log.level, info by default. Calling it
+// again closes the previous log file.
+func Init(cfg *config.Config) error {
+	logLevel := LevelInfo
+	if cfg.Log.Level != "" {
+		var err error
+		if logLevel, err = ParseLevel(cfg.Log.Level); err != nil {
+			return err
+		}
+	}
+	if cfg.Log.Debug {
+		logLevel = LevelDebug
+	}
+	logFormat, err := ParseFormat(cfg.Log.Format)
+	if err != nil {
+		return err
+	}
+	Close()
+
+	SetLevel(logLevel)
+	writeMu.Lock()
+	outputFormat = logFormat
+	logFile = newLogFile(cfg)
+	fileOutput = logFile
+	writeMu.Unlock()
+
+	queueMu.Lock()
+	queue = make(chan logEntry, queueSize)
 	wg.Add(1)
-	go processLogQueue()
-	if debugMode {
+	go processLogQueue(queue)
+	queueMu.Unlock()
+
+	if Enabled(LevelDebug) {
 		configJSON, _ := json.MarshalIndent(cfg, "", "  ")
 		Debugf("Loaded configuration: %s", configJSON)
 	}
+	return nil
 }
 
-func initializeLoggers(logFile string) {
-	logFlags := log.Ldate | log.Ltime
-	if debugMode {
-		logFlags |= log.Lmicroseconds
+// newLogFile returns the rotated log file, with the defaults for the
+// rotation settings left at zero.
+func newLogFile(cfg *config.Config) *lumberjack.Logger {
+	output := &lumberjack.Logger{
+		Filename:   cfg.Log.File,
+		MaxSize:    cfg.Log.MaxSize,
+		MaxBackups: cfg.Log.MaxBackups,
+		MaxAge:     cfg.Log.MaxAge,
+		Compress:   cfg.Log.Compress == nil || *cfg.Log.Compress,
 	}
-
-	// File logger: Plain text, no colors
-	fileOutput := &lumberjack.Logger{
-		Filename:   logFile,
-		MaxSize:    10, // megabytes
-		MaxBackups: 3,
-		MaxAge:     28, // days
-		Compress:   true,
+	if output.MaxSize == 0 {
+		output.MaxSize = defaultMaxSize
 	}
-	fileInfoLogger = log.New(fileOutput, "INFO:  ", logFlags)
-	fileWarnLogger = log.New(fileOutput, "WARN:  ", logFlags)
-	fileErrorLogger = log.New(fileOutput, "ERROR: ", logFlags)
-	fileFatalLogger = log.New(fileOutput, "FATAL: ", logFlags)
-	fileDebugLogger = log.New(fileOutput, "DEBUG: ", logFlags)
-
-	// Console logger: Colored output
-	consoleInfoLogger = log.New(os.Stdout, fmt.Sprintf("%sINFO:  %s", Green, Reset), logFlags)
-	consoleWarnLogger = log.New(os.Stdout, fmt.Sprintf("%sWARN:  %s", Yellow, Reset), logFlags)
-	consoleErrorLogger = log.New(os.Stderr, fmt.Sprintf("%sERROR: %s", Red, Reset), logFlags)
-	consoleFatalLogger = log.New(os.Stderr, fmt.Sprintf("%sFATAL: %s", Red, Reset), logFlags)
-	consoleDebugLogger = log.New(os.Stdout, fmt.Sprintf("%sDEBUG: %s", Blue, Reset), logFlags)
+	if output.MaxBackups == 0 {
+		output.MaxBackups = defaultMaxBackups
+	}
+	if output.MaxAge == 0 {
+		output.MaxAge = defaultMaxAge
+	}
+	return output
 }
 
-func processLogQueue() {
+// processLogQueue writes the entries of queue until it is closed. Once it
+// catches up after dropping entries, it logs how many were dropped.
+func processLogQueue(queue <-chan logEntry) {
 	defer wg.Done()
-	for entry := range logQueue {
-		switch entry.level {
-		case "INFO":
-			consoleInfoLogger.Println(entry.message)
-			fileInfoLogger.Println(entry.message)
-		case "WARN":
-			consoleWarnLogger.Println(entry.message)
-			fileWarnLogger.Println(entry.message)
-		case "ERROR":
-			consoleErrorLogger.Println(entry.message)
-			fileErrorLogger.Println(entry.message)
-		case "FATAL":
-			consoleFatalLogger.Fatalln(entry.message)
-			fileFatalLogger.Fatalln(entry.message)
-		case "DEBUG":
-			if debugMode {
-				consoleDebugLogger.Println(entry.message)
-				fileDebugLogger.Println(entry.message)
+	for entry := range queue {
+		write(entry)
+		if len(queue) == 0 {
+			if n := dropped.Swap(0); n > 0 {
+				write(logEntry{time: time.Now(), level: LevelWarn, message: "Dropped log entries because the log queue was full",
+					fields: []Field{Int64("dropped", n)}})
 			}
 		}
 	}
 }
 
-func Info(message string) {
-	logQueue <- logEntry{level: "INFO", message: message}
+// write writes entry to the console and the log file.
+func write(entry logEntry) {
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	console := consoleOutput
+	if entry.level >= LevelError {
+		console = consoleErrors
+	}
+	// Only text is colored, so that JSON and logfmt stay machine readable
+	io.WriteString(console, outputFormat.format(entry, true))
+	io.WriteString(fileOutput, outputFormat.format(entry, false))
 }
 
-func Warn(message string) {
-	logQueue <- logEntry{level: "WARN", message: message}
+// dispatch hands entry to processLogQueue without ever blocking, dropping it
+// when the queue is full. Fatal entries are written once every queued entry
+// is, and then the process exits.
+func dispatch(entry logEntry) {
+	if entry.level == LevelFatal {
+		stop()
+		write(entry)
+		closeFile()
+		os.Exit(1)
+	}
+	queueMu.RLock()
+	defer queueMu.RUnlock()
+	if queue == nil {
+		write(entry)
+		return
+	}
+	select {
+	case queue <- entry:
+	default:
+		dropped.Add(1)
+		droppedEntries.Inc()
+	}
 }
 
-func Error(message string) {
-	logQueue <- logEntry{level: "ERROR", message: message}
+// stop waits for processLogQueue to write the queued entries and exit.
+func stop() {
+	queueMu.Lock()
+	stopping := queue
+	queue = nil
+	queueMu.Unlock()
+	if stopping != nil {
+		close(stopping)
+		wg.Wait()
+	}
 }
 
-func Fatal(message string) {
-	logQueue <- logEntry{level: "FATAL", message: message}
-	processLogQueue()
+func closeFile() {
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	if logFile != nil {
+		logFile.Close()
+		logFile = nil
+	}
+	fileOutput = io.Discard
 }
 
-func Debug(message string) {
-	if debugMode {
-		logQueue <- logEntry{level: "DEBUG", message: message}
-	}
+// SetLevel changes the level at runtime, as done by CONFIG SET log_level.
+func SetLevel(l Level) {
+	level.Store(int32(l))
+}
+
+func GetLevel() Level {
+	return Level(level.Load())
+}
+
+// Enabled reports whether entries of level l are logged.
+func Enabled(l Level) bool {
+	return l >= GetLevel()
+}
+
+func Info(message string, fields ...Field) {
+	root.log(LevelInfo, message, fields)
+}
+
+func Warn(message string, fields ...Field) {
+	root.log(LevelWarn, message, fields)
+}
+
+func Error(message string, fields ...Field) {
+	root.log(LevelError, message, fields)
+}
+
+// Fatal logs message once every queued entry is written, and exits.
+func Fatal(message string, fields ...Field) {
+	root.log(LevelFatal, message, fields)
+}
+
+func Debug(message string, fields ...Field) {
+	root.log(LevelDebug, message, fields)
 }
 
 func Infof(format string, v ...interface{}) {
-	msg := fmt.Sprintf(format, v...)
-	logQueue <- logEntry{level: "INFO", message: msg}
+	if Enabled(LevelInfo) {
+		root.log(LevelInfo, fmt.Sprintf(format, v...), nil)
+	}
 }
 
 func Warnf(format string, v ...interface{}) {
-	msg := fmt.Sprintf(format, v...)
-	logQueue <- logEntry{level: "WARN", message: msg}
+	if Enabled(LevelWarn) {
+		root.log(LevelWarn, fmt.Sprintf(format, v...), nil)
+	}
 }
 
 func Errorf(format string, v ...interface{}) {
-	msg := fmt.Sprintf(format, v...)
-	logQueue <- logEntry{level: "ERROR", message: msg}
+	if Enabled(LevelError) {
+		root.log(LevelError, fmt.Sprintf(format, v...), nil)
+	}
 }
 
 func Fatalf(format string, v ...interface{}) {
-	msg := fmt.Sprintf(format, v...)
-	logQueue <- logEntry{level: "FATAL", message: msg}
-	processLogQueue()
+	root.log(LevelFatal, fmt.Sprintf(format, v...), nil)
 }
 
 func Debugf(format string, v ...interface{}) {
-	if debugMode {
-		msg := fmt.Sprintf(format, v...)
-		logQueue <- logEntry{level: "DEBUG", message: msg}
+	if Enabled(LevelDebug) {
+		root.log(LevelDebug, fmt.Sprintf(format, v...), nil)
 	}
 }
 
-func StructuredInfo(fields map[string]interface{}) {
-	jsonLog, _ := json.Marshal(fields)
-	Info(string(jsonLog))
+// InfoWithContext logs message with the fields of the logger carried by ctx,
+// such as its request ID.
+func InfoWithContext(ctx context.Context, message string, fields ...Field) {
+	FromContext(ctx).Info(message, fields...)
 }
 
-func InfoWithContext(ctx context.Context, message string) {
-	requestID := ctx.Value("requestID")
-	if requestID != nil {
-		Infof("[RequestID: %v] %s", requestID, message)
+func SetDebugMode(debug bool) {
+	if debug {
+		SetLevel(LevelDebug)
 	} else {
-		Info(message)
+		SetLevel(LevelInfo)
 	}
 }
 
-func SetDebugMode(debug bool) {
-	debugMode = debug
-}
-
+// Close writes the queued entries and closes the log file. Entries logged
+// afterwards are only written to the console.
 func Close() {
-	close(logQueue)
-	wg.Wait()
+	stop()
+	closeFile()
 }
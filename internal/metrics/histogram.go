@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LatencyBuckets are the default histogram buckets in seconds, from 10µs to
+// 1s, suited to an in-memory store.
+var LatencyBuckets = []float64{0.00001, 0.000025, 0.00005, 0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+// Histogram counts observations in cumulative buckets by upper bound.
+type Histogram struct {
+	name    string
+	help    string
+	buckets []float64
+	counts  []atomic.Int64 // one per bucket, not cumulative
+	count   atomic.Int64
+	sumBits atomic.Uint64 // float64 bits of the sum of observations
+}
+
+func newHistogram(name, help string, buckets []float64) *Histogram {
+	return &Histogram{name: name, help: help, buckets: buckets, counts: make([]atomic.Int64, len(buckets))}
+}
+
+// NewHistogram registers a histogram with the given bucket upper bounds, in
+// increasing order.
+func NewHistogram(name, help string, buckets []float64) *Histogram {
+	return register(newHistogram(name, help, buckets)).(*Histogram)
+}
+
+func (h *Histogram) Observe(value float64) {
+	for i, bound := range h.buckets {
+		if value <= bound {
+			h.counts[i].Add(1)
+			break
+		}
+	}
+	h.count.Add(1)
+	for {
+		old := h.sumBits.Load()
+		if h.sumBits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+value)) {
+			return
+		}
+	}
+}
+
+// ObserveDuration observes d in seconds.
+func (h *Histogram) ObserveDuration(d time.Duration) {
+	h.Observe(d.Seconds())
+}
+
+// Count returns the number of observations.
+func (h *Histogram) Count() int64 {
+	return h.count.Load()
+}
+
+// Sum returns the sum of all observations.
+func (h *Histogram) Sum() float64 {
+	return math.Float64frombits(h.sumBits.Load())
+}
+
+func (h *Histogram) describe() (string, string, string) {
+	return h.name, h.help, typeHistogram
+}
+
+func (h *Histogram) collect() []sample {
+	return h.samples("", "")
+}
+
+// samples returns the buckets, sum and count, with an extra label when label
+// is set.
+func (h *Histogram) samples(label, value string) []sample {
+	samples := make([]sample, 0, len(h.buckets)+3)
+	var cumulative int64
+	for i, bound := range h.buckets {
+		cumulative += h.counts[i].Load()
+		samples = append(samples, sample{"_bucket", formatLabels(label, value, "le", formatFloat(bound)), float64(cumulative)})
+	}
+	samples = append(samples,
+		sample{"_bucket", formatLabels(label, value, "le", "+Inf"), float64(h.Count())},
+		sample{"_sum", formatLabels(label, value), h.Sum()},
+		sample{"_count", formatLabels(label, value), float64(h.Count())},
+	)
+	return samples
+}
+
+// HistogramVec is a family of histograms told apart by the value of one
+// label.
+type HistogramVec struct {
+	name       string
+	help       string
+	label      string
+	buckets    []float64
+	histograms sync.Map // label value to *Histogram
+}
+
+// NewHistogramVec registers a histogram family with the given label.
+func NewHistogramVec(name, help, label string, buckets []float64) *HistogramVec {
+	return register(&HistogramVec{name: name, help: help, label: label, buckets: buckets}).(*HistogramVec)
+}
+
+// With returns the histogram for the label value, creating it empty.
+func (v *HistogramVec) With(value string) *Histogram {
+	if histogram, ok := v.histograms.Load(value); ok {
+		return histogram.(*Histogram)
+	}
+	histogram, _ := v.histograms.LoadOrStore(value, newHistogram(v.name, v.help, v.buckets))
+	return histogram.(*Histogram)
+}
+
+func (v *HistogramVec) describe() (string, string, string) {
+	return v.name, v.help, typeHistogram
+}
+
+func (v *HistogramVec) collect() []sample {
+	var samples []sample
+	for _, value := range sortedKeys(&v.histograms) {
+		samples = append(samples, v.With(value).samples(v.label, value)...)
+	}
+	return samples
+}
@@ -0,0 +1,182 @@
+// Package metrics holds the server's counters so they can be reported by
+// commands and exporters without depending on the packages that update them.
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Metric types of the Prometheus exposition format.
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+// sample is one value of a metric family. suffix is appended to the family
+// name, as in _bucket, and labels is the formatted label set, empty or as in
+// {command="get"}.
+type sample struct {
+	suffix string
+	labels string
+	value  float64
+}
+
+// collector is a registered metric family.
+type collector interface {
+	describe() (name, help, kind string)
+	collect() []sample
+}
+
+var (
+	mu         sync.Mutex
+	collectors = make(map[string]collector)
+)
+
+// register adds c under its name unless a collector of that name exists, in
+// which case the existing one is returned.
+func register(c collector) collector {
+	mu.Lock()
+	defer mu.Unlock()
+	name, _, _ := c.describe()
+	if existing, ok := collectors[name]; ok {
+		return existing
+	}
+	collectors[name] = c
+	return c
+}
+
+// registered returns every collector sorted by name.
+func registered() []collector {
+	mu.Lock()
+	defer mu.Unlock()
+	list := make([]collector, 0, len(collectors))
+	for _, c := range collectors {
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, _, _ := list[i].describe()
+		b, _, _ := list[j].describe()
+		return a < b
+	})
+	return list
+}
+
+// Counter is a monotonically increasing value.
+type Counter struct {
+	name  string
+	help  string
+	value atomic.Int64
+}
+
+func (c *Counter) Inc() {
+	c.value.Add(1)
+}
+
+func (c *Counter) Add(delta int64) {
+	c.value.Add(delta)
+}
+
+func (c *Counter) Value() int64 {
+	return c.value.Load()
+}
+
+func (c *Counter) Name() string {
+	return c.name
+}
+
+func (c *Counter) Help() string {
+	return c.help
+}
+
+func (c *Counter) describe() (string, string, string) {
+	return c.name, c.help, typeCounter
+}
+
+func (c *Counter) collect() []sample {
+	return []sample{{value: float64(c.Value())}}
+}
+
+// NewCounter registers a counter. Registering the same name twice returns the
+// counter registered first.
+func NewCounter(name, help string) *Counter {
+	return register(&Counter{name: name, help: help}).(*Counter)
+}
+
+// CounterVec is a family of counters told apart by the value of one label,
+// such as the command name.
+type CounterVec struct {
+	name     string
+	help     string
+	label    string
+	counters sync.Map // label value to *Counter
+}
+
+// NewCounterVec registers a counter family with the given label.
+func NewCounterVec(name, help, label string) *CounterVec {
+	return register(&CounterVec{name: name, help: help, label: label}).(*CounterVec)
+}
+
+// With returns the counter for the label value, creating it at zero.
+func (v *CounterVec) With(value string) *Counter {
+	if counter, ok := v.counters.Load(value); ok {
+		return counter.(*Counter)
+	}
+	counter, _ := v.counters.LoadOrStore(value, &Counter{name: v.name, help: v.help})
+	return counter.(*Counter)
+}
+
+// Each calls fn for every label value in sorted order.
+func (v *CounterVec) Each(fn func(value string, counter *Counter)) {
+	for _, value := range sortedKeys(&v.counters) {
+		fn(value, v.With(value))
+	}
+}
+
+func (v *CounterVec) describe() (string, string, string) {
+	return v.name, v.help, typeCounter
+}
+
+func (v *CounterVec) collect() []sample {
+	var samples []sample
+	v.Each(func(value string, counter *Counter) {
+		samples = append(samples, sample{labels: formatLabels(v.label, value), value: float64(counter.Value())})
+	})
+	return samples
+}
+
+// GaugeFunc is a value that can go up and down, read from a function when
+// collected.
+type GaugeFunc struct {
+	name string
+	help string
+	fn   atomic.Pointer[func() float64]
+}
+
+// NewGaugeFunc registers a gauge reporting fn. Registering the same name again
+// replaces the function, so a restarted server reports its own state.
+func NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	gauge := register(&GaugeFunc{name: name, help: help}).(*GaugeFunc)
+	gauge.fn.Store(&fn)
+	return gauge
+}
+
+func (g *GaugeFunc) describe() (string, string, string) {
+	return g.name, g.help, typeGauge
+}
+
+func (g *GaugeFunc) collect() []sample {
+	return []sample{{value: (*g.fn.Load())()}}
+}
+
+func sortedKeys(m *sync.Map) []string {
+	var keys []string
+	m.Range(func(key, value interface{}) bool {
+		keys = append(keys, key.(string))
+		return true
+	})
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// contentType is the Prometheus text exposition format.
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// WritePrometheus writes every registered metric in the Prometheus text
+// exposition format.
+func WritePrometheus(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, c := range registered() {
+		name, help, kind := c.describe()
+		bw.WriteString("# HELP " + name + " " + escapeHelp(help) + "\n")
+		bw.WriteString("# TYPE " + name + " " + kind + "\n")
+		for _, s := range c.collect() {
+			bw.WriteString(name + s.suffix + s.labels + " " + formatFloat(s.value) + "\n")
+		}
+	}
+	return bw.Flush()
+}
+
+// Handler serves the metrics for Prometheus to scrape.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		WritePrometheus(w)
+	})
+}
+
+// formatLabels formats name, value pairs as a label set, skipping pairs with
+// an empty name.
+func formatLabels(pairs ...string) string {
+	var b strings.Builder
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i] == "" {
+			continue
+		}
+		if b.Len() == 0 {
+			b.WriteByte('{')
+		} else {
+			b.WriteByte(',')
+		}
+		b.WriteString(pairs[i] + `="` + escapeLabelValue(pairs[i+1]) + `"`)
+	}
+	if b.Len() > 0 {
+		b.WriteByte('}')
+	}
+	return b.String()
+}
+
+var (
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,103 @@
+// Package ratelimit implements token buckets keyed by an arbitrary string,
+// such as a user name or a client IP.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// pruneInterval is how often buckets that have refilled completely are
+// dropped. A full bucket behaves exactly like a missing one.
+const pruneInterval = time.Minute
+
+// Limit is a sustained rate in events per second and a burst, the number of
+// events allowed at once. A zero Rate means no limit.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// burst returns the bucket capacity, at least one event.
+func (l Limit) burst() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return max(l.Rate, 1)
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter holds one token bucket per key.
+type Limiter struct {
+	mu        sync.Mutex
+	limit     Limit
+	buckets   map[string]*bucket
+	lastPrune time.Time
+}
+
+func New(limit Limit) *Limiter {
+	return &Limiter{limit: limit, buckets: make(map[string]*bucket)}
+}
+
+// Allow takes a token from the bucket of key and reports whether there was
+// one.
+func (l *Limiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.limit.Rate <= 0 {
+		return true
+	}
+
+	now := time.Now()
+	l.prune(now)
+	capacity := l.limit.burst()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = min(capacity, b.tokens+now.Sub(b.last).Seconds()*l.limit.Rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Limit returns the current limit.
+func (l *Limiter) Limit() Limit {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
+
+// SetLimit changes the limit. Existing buckets keep their tokens, capped to
+// the new burst.
+func (l *Limiter) SetLimit(limit Limit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = limit
+	capacity := limit.burst()
+	for _, b := range l.buckets {
+		b.tokens = min(b.tokens, capacity)
+	}
+}
+
+// prune drops the buckets that are full again. Callers hold mu.
+func (l *Limiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < pruneInterval {
+		return
+	}
+	l.lastPrune = now
+	capacity := l.limit.burst()
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.limit.Rate >= capacity {
+			delete(l.buckets, key)
+		}
+	}
+}
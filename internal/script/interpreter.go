@@ -0,0 +1,679 @@
+package script
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxStringLength bounds the strings a script can build by concatenation.
+	maxStringLength = 64 * 1024 * 1024
+	// maxListLength bounds the lists a script can build.
+	maxListLength = 1 << 20
+	// maxScriptMemory bounds the bytes a single run may allocate for strings
+	// and list items in total, freed or not.
+	maxScriptMemory = 256 * 1024 * 1024
+	// listItemSize is what a list item is charged, the size of a Value.
+	listItemSize = 16
+	// deadlineCheckInterval is the number of steps between deadline checks.
+	deadlineCheckInterval = 1024
+	// maxEvalDepth bounds the recursion of the interpreter. The parser limits
+	// nesting, but chains such as a + b + c or a[1][2] still build deep trees.
+	maxEvalDepth = 10000
+)
+
+type control int
+
+const (
+	ctrlNone control = iota
+	ctrlBreak
+	ctrlReturn
+)
+
+type scope struct {
+	vars   map[string]Value
+	parent *scope
+}
+
+func newScope(parent *scope) *scope {
+	return &scope{vars: make(map[string]Value), parent: parent}
+}
+
+func (s *scope) lookup(name string) (*scope, bool) {
+	for current := s; current != nil; current = current.parent {
+		if _, ok := current.vars[name]; ok {
+			return current, true
+		}
+	}
+	return nil, false
+}
+
+type interpreter struct {
+	env       *Env
+	steps     int
+	depth     int
+	allocated int
+}
+
+// tick counts an execution step and enforces the deadline.
+func (in *interpreter) tick() error {
+	in.steps++
+	if in.steps%deadlineCheckInterval == 0 && in.env.expired() {
+		return ErrTimeout
+	}
+	return nil
+}
+
+// alloc charges n bytes to the memory budget of the run.
+func (in *interpreter) alloc(n int) error {
+	in.allocated += n
+	if in.allocated > maxScriptMemory {
+		return ErrMemoryLimit
+	}
+	return nil
+}
+
+// eval evaluates e, failing instead of recursing past maxEvalDepth.
+func (in *interpreter) eval(e expr, sc *scope) (Value, error) {
+	in.depth++
+	defer func() { in.depth-- }()
+	if in.depth > maxEvalDepth {
+		return nil, fmt.Errorf("expression too deeply nested")
+	}
+	return e.eval(in, sc)
+}
+
+func (in *interpreter) execBlock(body []stmt, sc *scope) (control, Value, error) {
+	in.depth++
+	defer func() { in.depth-- }()
+	if in.depth > maxEvalDepth {
+		return ctrlNone, nil, fmt.Errorf("blocks too deeply nested")
+	}
+	for _, s := range body {
+		if err := in.tick(); err != nil {
+			return ctrlNone, nil, err
+		}
+		ctrl, value, err := s.exec(in, sc)
+		if err != nil {
+			var scriptErr *Error
+			var callErr *CallError
+			if !errors.As(err, &scriptErr) && !errors.As(err, &callErr) && err != ErrTimeout && err != ErrMemoryLimit {
+				err = &Error{Line: s.lineNumber(), Msg: err.Error()}
+			}
+			return ctrlNone, nil, err
+		}
+		if ctrl != ctrlNone {
+			return ctrl, value, nil
+		}
+	}
+	return ctrlNone, nil, nil
+}
+
+type stmt interface {
+	exec(in *interpreter, sc *scope) (control, Value, error)
+	lineNumber() int
+}
+
+type expr interface {
+	eval(in *interpreter, sc *scope) (Value, error)
+}
+
+type localStmt struct {
+	line  int
+	name  string
+	value expr
+}
+
+func (s *localStmt) lineNumber() int { return s.line }
+
+func (s *localStmt) exec(in *interpreter, sc *scope) (control, Value, error) {
+	var value Value
+	if s.value != nil {
+		var err error
+		if value, err = in.eval(s.value, sc); err != nil {
+			return ctrlNone, nil, err
+		}
+	}
+	sc.vars[s.name] = value
+	return ctrlNone, nil, nil
+}
+
+type assignStmt struct {
+	line   int
+	target expr
+	value  expr
+}
+
+func (s *assignStmt) lineNumber() int { return s.line }
+
+func (s *assignStmt) exec(in *interpreter, sc *scope) (control, Value, error) {
+	value, err := in.eval(s.value, sc)
+	if err != nil {
+		return ctrlNone, nil, err
+	}
+
+	switch target := s.target.(type) {
+	case *nameExpr:
+		owner, ok := sc.lookup(target.name)
+		if !ok {
+			return ctrlNone, nil, fmt.Errorf("assignment to undeclared variable '%s', declare it with local", target.name)
+		}
+		owner.vars[target.name] = value
+	case *indexExpr:
+		object, err := in.eval(target.object, sc)
+		if err != nil {
+			return ctrlNone, nil, err
+		}
+		list, ok := object.(*List)
+		if !ok {
+			return ctrlNone, nil, fmt.Errorf("attempt to index a %s value", TypeName(object))
+		}
+		key, err := in.eval(target.key, sc)
+		if err != nil {
+			return ctrlNone, nil, err
+		}
+		index, ok := listIndex(key)
+		if !ok || index < 1 || index > len(list.Items)+1 {
+			return ctrlNone, nil, fmt.Errorf("list index out of range")
+		}
+		if index == len(list.Items)+1 {
+			if len(list.Items) >= maxListLength {
+				return ctrlNone, nil, fmt.Errorf("list too long")
+			}
+			if err := in.alloc(listItemSize); err != nil {
+				return ctrlNone, nil, err
+			}
+			list.Items = append(list.Items, value)
+		} else {
+			list.Items[index-1] = value
+		}
+	}
+	return ctrlNone, nil, nil
+}
+
+type callStmt struct {
+	line int
+	call *callExpr
+}
+
+func (s *callStmt) lineNumber() int { return s.line }
+
+func (s *callStmt) exec(in *interpreter, sc *scope) (control, Value, error) {
+	_, err := in.eval(s.call, sc)
+	return ctrlNone, nil, err
+}
+
+type ifStmt struct {
+	line      int
+	conds     []expr
+	blocks    [][]stmt
+	elseBlock []stmt
+}
+
+func (s *ifStmt) lineNumber() int { return s.line }
+
+func (s *ifStmt) exec(in *interpreter, sc *scope) (control, Value, error) {
+	for i, cond := range s.conds {
+		value, err := in.eval(cond, sc)
+		if err != nil {
+			return ctrlNone, nil, err
+		}
+		if Truthy(value) {
+			return in.execBlock(s.blocks[i], newScope(sc))
+		}
+	}
+	if s.elseBlock != nil {
+		return in.execBlock(s.elseBlock, newScope(sc))
+	}
+	return ctrlNone, nil, nil
+}
+
+type whileStmt struct {
+	line int
+	cond expr
+	body []stmt
+}
+
+func (s *whileStmt) lineNumber() int { return s.line }
+
+func (s *whileStmt) exec(in *interpreter, sc *scope) (control, Value, error) {
+	for {
+		if err := in.tick(); err != nil {
+			return ctrlNone, nil, err
+		}
+		value, err := in.eval(s.cond, sc)
+		if err != nil {
+			return ctrlNone, nil, err
+		}
+		if !Truthy(value) {
+			return ctrlNone, nil, nil
+		}
+		ctrl, result, err := in.execBlock(s.body, newScope(sc))
+		if err != nil || ctrl == ctrlReturn {
+			return ctrl, result, err
+		}
+		if ctrl == ctrlBreak {
+			return ctrlNone, nil, nil
+		}
+	}
+}
+
+type forStmt struct {
+	line              int
+	name              string
+	start, stop, step expr
+	body              []stmt
+}
+
+func (s *forStmt) lineNumber() int { return s.line }
+
+func (s *forStmt) exec(in *interpreter, sc *scope) (control, Value, error) {
+	bounds := []float64{0, 0, 1}
+	for i, e := range []expr{s.start, s.stop, s.step} {
+		if e == nil {
+			continue
+		}
+		value, err := in.eval(e, sc)
+		if err != nil {
+			return ctrlNone, nil, err
+		}
+		n, ok := ToNumber(value)
+		if !ok {
+			return ctrlNone, nil, fmt.Errorf("'for' bounds must be numbers")
+		}
+		bounds[i] = n
+	}
+	start, stop, step := bounds[0], bounds[1], bounds[2]
+	if step == 0 {
+		return ctrlNone, nil, fmt.Errorf("'for' step is zero")
+	}
+
+	for i := start; (step > 0 && i <= stop) || (step < 0 && i >= stop); i += step {
+		if err := in.tick(); err != nil {
+			return ctrlNone, nil, err
+		}
+		body := newScope(sc)
+		body.vars[s.name] = i
+		ctrl, result, err := in.execBlock(s.body, body)
+		if err != nil || ctrl == ctrlReturn {
+			return ctrl, result, err
+		}
+		if ctrl == ctrlBreak {
+			break
+		}
+	}
+	return ctrlNone, nil, nil
+}
+
+type doStmt struct {
+	line int
+	body []stmt
+}
+
+func (s *doStmt) lineNumber() int { return s.line }
+
+func (s *doStmt) exec(in *interpreter, sc *scope) (control, Value, error) {
+	return in.execBlock(s.body, newScope(sc))
+}
+
+type returnStmt struct {
+	line  int
+	value expr
+}
+
+func (s *returnStmt) lineNumber() int { return s.line }
+
+func (s *returnStmt) exec(in *interpreter, sc *scope) (control, Value, error) {
+	if s.value == nil {
+		return ctrlReturn, nil, nil
+	}
+	value, err := in.eval(s.value, sc)
+	return ctrlReturn, value, err
+}
+
+type breakStmt struct {
+	line int
+}
+
+func (s *breakStmt) lineNumber() int { return s.line }
+
+func (s *breakStmt) exec(in *interpreter, sc *scope) (control, Value, error) {
+	return ctrlBreak, nil, nil
+}
+
+type literal struct {
+	value Value
+}
+
+func (e *literal) eval(in *interpreter, sc *scope) (Value, error) {
+	return e.value, nil
+}
+
+type nameExpr struct {
+	line int
+	name string
+}
+
+func (e *nameExpr) eval(in *interpreter, sc *scope) (Value, error) {
+	owner, ok := sc.lookup(e.name)
+	if !ok {
+		return nil, fmt.Errorf("undefined variable '%s'", e.name)
+	}
+	return owner.vars[e.name], nil
+}
+
+type indexExpr struct {
+	line   int
+	object expr
+	key    expr
+}
+
+func (e *indexExpr) eval(in *interpreter, sc *scope) (Value, error) {
+	object, err := in.eval(e.object, sc)
+	if err != nil {
+		return nil, err
+	}
+	list, ok := object.(*List)
+	if !ok {
+		return nil, fmt.Errorf("attempt to index a %s value", TypeName(object))
+	}
+	key, err := in.eval(e.key, sc)
+	if err != nil {
+		return nil, err
+	}
+	index, ok := listIndex(key)
+	if !ok || index < 1 || index > len(list.Items) {
+		return nil, nil
+	}
+	return list.Items[index-1], nil
+}
+
+// listIndex converts a value used as a list index to an int.
+func listIndex(key Value) (int, bool) {
+	n, ok := key.(float64)
+	if !ok || n != math.Trunc(n) || n > maxListLength+1 || n < 0 {
+		return 0, false
+	}
+	return int(n), true
+}
+
+type listExpr struct {
+	line  int
+	items []expr
+}
+
+func (e *listExpr) eval(in *interpreter, sc *scope) (Value, error) {
+	if err := in.alloc(len(e.items) * listItemSize); err != nil {
+		return nil, err
+	}
+	list := &List{Items: make([]Value, 0, len(e.items))}
+	for _, item := range e.items {
+		value, err := in.eval(item, sc)
+		if err != nil {
+			return nil, err
+		}
+		list.Items = append(list.Items, value)
+	}
+	return list, nil
+}
+
+type callExpr struct {
+	line int
+	name string
+	args []expr
+}
+
+func (e *callExpr) eval(in *interpreter, sc *scope) (Value, error) {
+	fn, ok := builtins[e.name]
+	if !ok {
+		return nil, fmt.Errorf("attempt to call unknown function '%s'", e.name)
+	}
+	args := make([]Value, len(e.args))
+	for i, arg := range e.args {
+		value, err := in.eval(arg, sc)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = value
+	}
+	if err := in.tick(); err != nil {
+		return nil, err
+	}
+	return fn(in, args)
+}
+
+type unaryExpr struct {
+	line    int
+	op      string
+	operand expr
+}
+
+func (e *unaryExpr) eval(in *interpreter, sc *scope) (Value, error) {
+	value, err := in.eval(e.operand, sc)
+	if err != nil {
+		return nil, err
+	}
+	switch e.op {
+	case "not":
+		return !Truthy(value), nil
+	case "-":
+		n, ok := ToNumber(value)
+		if !ok {
+			return nil, fmt.Errorf("attempt to perform arithmetic on a %s value", TypeName(value))
+		}
+		return -n, nil
+	default: // "#"
+		switch v := value.(type) {
+		case string:
+			return float64(len(v)), nil
+		case *List:
+			return float64(len(v.Items)), nil
+		}
+		return nil, fmt.Errorf("attempt to get length of a %s value", TypeName(value))
+	}
+}
+
+type binaryExpr struct {
+	line        int
+	op          string
+	left, right expr
+}
+
+func (e *binaryExpr) eval(in *interpreter, sc *scope) (Value, error) {
+	left, err := in.eval(e.left, sc)
+	if err != nil {
+		return nil, err
+	}
+
+	// and/or short-circuit and yield one of their operands, as in Lua
+	switch e.op {
+	case "and":
+		if !Truthy(left) {
+			return left, nil
+		}
+		return in.eval(e.right, sc)
+	case "or":
+		if Truthy(left) {
+			return left, nil
+		}
+		return in.eval(e.right, sc)
+	}
+
+	right, err := in.eval(e.right, sc)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.op {
+	case "==":
+		return equal(left, right), nil
+	case "~=", "!=":
+		return !equal(left, right), nil
+	case "<", "<=", ">", ">=":
+		return compare(e.op, left, right)
+	case "..":
+		l, lok := concatOperand(left)
+		r, rok := concatOperand(right)
+		if !lok || !rok {
+			bad := left
+			if lok {
+				bad = right
+			}
+			return nil, fmt.Errorf("attempt to concatenate a %s value", TypeName(bad))
+		}
+		if len(l)+len(r) > maxStringLength {
+			return nil, fmt.Errorf("string too long")
+		}
+		if err := in.alloc(len(l) + len(r)); err != nil {
+			return nil, err
+		}
+		return l + r, nil
+	}
+	return arithmetic(e.op, left, right)
+}
+
+func equal(left, right Value) bool {
+	switch l := left.(type) {
+	case *List:
+		r, ok := right.(*List)
+		return ok && l == r
+	}
+	return left == right
+}
+
+func compare(op string, left, right Value) (Value, error) {
+	var cmp int
+	switch l := left.(type) {
+	case float64:
+		r, ok := right.(float64)
+		if !ok {
+			return nil, fmt.Errorf("attempt to compare number with %s", TypeName(right))
+		}
+		switch {
+		case l < r:
+			cmp = -1
+		case l > r:
+			cmp = 1
+		}
+	case string:
+		r, ok := right.(string)
+		if !ok {
+			return nil, fmt.Errorf("attempt to compare string with %s", TypeName(right))
+		}
+		cmp = strings.Compare(l, r)
+	default:
+		return nil, fmt.Errorf("attempt to compare two %s values", TypeName(left))
+	}
+
+	switch op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	}
+	return cmp >= 0, nil
+}
+
+func arithmetic(op string, left, right Value) (Value, error) {
+	l, lok := ToNumber(left)
+	r, rok := ToNumber(right)
+	if !lok || !rok {
+		bad := left
+		if lok {
+			bad = right
+		}
+		return nil, fmt.Errorf("attempt to perform arithmetic on a %s value", TypeName(bad))
+	}
+	switch op {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/":
+		return l / r, nil
+	}
+	// "%" follows Lua: the result has the sign of the divisor
+	return l - math.Floor(l/r)*r, nil
+}
+
+func concatOperand(value Value) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case float64:
+		return FormatNumber(v), true
+	}
+	return "", false
+}
+
+// Truthy reports whether value counts as true in a condition. Only nil and
+// false are false.
+func Truthy(value Value) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	}
+	return true
+}
+
+// ToNumber converts numbers and numeric strings to a float64.
+func ToNumber(value Value) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+			return 0, false
+		}
+		return n, true
+	}
+	return 0, false
+}
+
+// FormatNumber formats a number without an exponent, using the shortest
+// representation that round trips.
+func FormatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+// TypeName returns the script level name of the type of value.
+func TypeName(value Value) string {
+	switch value.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case *List:
+		return "list"
+	}
+	return "unknown"
+}
+
+// ToString converts a value to the string passed to commands.
+func ToString(value Value) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case float64:
+		return FormatNumber(v), true
+	case bool:
+		return strconv.FormatBool(v), true
+	case nil:
+		return "nil", true
+	}
+	return "", false
+}
@@ -0,0 +1,142 @@
+package script
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokName
+	tokKeyword
+	tokNumber
+	tokString
+	tokSymbol
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	num  float64
+	line int
+}
+
+var keywords = map[string]bool{
+	"and": true, "break": true, "do": true, "else": true, "elseif": true,
+	"end": true, "false": true, "for": true, "if": true, "local": true,
+	"nil": true, "not": true, "or": true, "return": true, "then": true,
+	"true": true, "while": true,
+}
+
+// symbols lists the operators and punctuation, longest first so that the
+// lexer always picks the longest match.
+var symbols = []string{
+	"==", "~=", "!=", "<=", ">=", "..",
+	"<", ">", "=", "+", "-", "*", "/", "%", "#",
+	"(", ")", "{", "}", "[", "]", ",", ";",
+}
+
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	line := 1
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case strings.HasPrefix(src[i:], "--"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case isLetter(c):
+			start := i
+			for i < len(src) && (isLetter(src[i]) || isDigit(src[i])) {
+				i++
+			}
+			word := src[start:i]
+			kind := tokName
+			if keywords[word] {
+				kind = tokKeyword
+			}
+			tokens = append(tokens, token{kind: kind, text: word, line: line})
+		case isDigit(c) || (c == '.' && i+1 < len(src) && isDigit(src[i+1])):
+			start := i
+			for i < len(src) && (isDigit(src[i]) || src[i] == '.' || src[i] == 'e' || src[i] == 'E' ||
+				((src[i] == '+' || src[i] == '-') && (src[i-1] == 'e' || src[i-1] == 'E'))) {
+				i++
+			}
+			num, err := strconv.ParseFloat(src[start:i], 64)
+			if err != nil {
+				return nil, &Error{Line: line, Msg: fmt.Sprintf("malformed number %q", src[start:i])}
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: src[start:i], num: num, line: line})
+		case c == '"' || c == '\'':
+			text, n, err := readString(src[i:], line)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokString, text: text, line: line})
+			i += n
+		default:
+			matched := false
+			for _, sym := range symbols {
+				if strings.HasPrefix(src[i:], sym) {
+					tokens = append(tokens, token{kind: tokSymbol, text: sym, line: line})
+					i += len(sym)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, &Error{Line: line, Msg: fmt.Sprintf("unexpected character %q", c)}
+			}
+		}
+	}
+	return append(tokens, token{kind: tokEOF, line: line}), nil
+}
+
+// readString reads a quoted string literal at the start of src and returns
+// its value and the number of bytes consumed.
+func readString(src string, line int) (string, int, error) {
+	quote := src[0]
+	var b strings.Builder
+	for i := 1; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == quote:
+			return b.String(), i + 1, nil
+		case c == '\n':
+			return "", 0, &Error{Line: line, Msg: "unfinished string"}
+		case c == '\\' && i+1 < len(src):
+			i++
+			switch src[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			default:
+				b.WriteByte(src[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, &Error{Line: line, Msg: "unfinished string"}
+}
+
+func isLetter(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
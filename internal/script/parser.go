@@ -0,0 +1,425 @@
+package script
+
+import "fmt"
+
+// maxNesting bounds how deeply blocks and expressions may nest, so that
+// neither the parser nor the interpreter can run out of stack.
+const maxNesting = 200
+
+type parser struct {
+	tokens []token
+	pos    int
+	depth  int
+}
+
+func parse(src string) ([]stmt, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	body, err := p.block()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, p.errorf(tok, "unexpected %s", describe(tok))
+	}
+	return body, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// is reports whether the current token is the given keyword or symbol.
+func (p *parser) is(text string) bool {
+	tok := p.peek()
+	return (tok.kind == tokKeyword || tok.kind == tokSymbol) && tok.text == text
+}
+
+func (p *parser) accept(text string) bool {
+	if p.is(text) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(text string) error {
+	if !p.accept(text) {
+		tok := p.peek()
+		return p.errorf(tok, "expected '%s' near %s", text, describe(tok))
+	}
+	return nil
+}
+
+func (p *parser) expectName() (token, error) {
+	tok := p.next()
+	if tok.kind != tokName {
+		return tok, p.errorf(tok, "expected a name near %s", describe(tok))
+	}
+	return tok, nil
+}
+
+func (p *parser) errorf(tok token, format string, args ...interface{}) error {
+	return &Error{Line: tok.line, Msg: fmt.Sprintf(format, args...)}
+}
+
+// enter descends one nesting level, failing past maxNesting. Every call has
+// to be paired with leave.
+func (p *parser) enter() error {
+	p.depth++
+	if p.depth > maxNesting {
+		return p.errorf(p.peek(), "too many nesting levels near %s", describe(p.peek()))
+	}
+	return nil
+}
+
+func (p *parser) leave() {
+	p.depth--
+}
+
+func describe(tok token) string {
+	if tok.kind == tokEOF {
+		return "end of script"
+	}
+	return fmt.Sprintf("'%s'", tok.text)
+}
+
+// blockEnd reports whether the current token terminates a block.
+func (p *parser) blockEnd() bool {
+	return p.peek().kind == tokEOF || p.is("end") || p.is("else") || p.is("elseif")
+}
+
+func (p *parser) block() ([]stmt, error) {
+	defer p.leave()
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	var body []stmt
+	for !p.blockEnd() {
+		if p.accept(";") {
+			continue
+		}
+		s, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		body = append(body, s)
+	}
+	return body, nil
+}
+
+func (p *parser) statement() (stmt, error) {
+	tok := p.peek()
+	switch {
+	case p.accept("local"):
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		s := &localStmt{line: tok.line, name: name.text}
+		if p.accept("=") {
+			if s.value, err = p.expression(); err != nil {
+				return nil, err
+			}
+		}
+		return s, nil
+	case p.accept("if"):
+		return p.ifStatement(tok.line)
+	case p.accept("while"):
+		cond, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		body, err := p.doBlock()
+		if err != nil {
+			return nil, err
+		}
+		return &whileStmt{line: tok.line, cond: cond, body: body}, nil
+	case p.accept("for"):
+		return p.forStatement(tok.line)
+	case p.accept("do"):
+		body, err := p.block()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("end"); err != nil {
+			return nil, err
+		}
+		return &doStmt{line: tok.line, body: body}, nil
+	case p.accept("return"):
+		s := &returnStmt{line: tok.line}
+		if !p.blockEnd() && !p.is(";") {
+			var err error
+			if s.value, err = p.expression(); err != nil {
+				return nil, err
+			}
+		}
+		return s, nil
+	case p.accept("break"):
+		return &breakStmt{line: tok.line}, nil
+	}
+
+	target, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	if p.accept("=") {
+		switch target.(type) {
+		case *nameExpr, *indexExpr:
+		default:
+			return nil, p.errorf(tok, "cannot assign to this expression")
+		}
+		value, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		return &assignStmt{line: tok.line, target: target, value: value}, nil
+	}
+	call, ok := target.(*callExpr)
+	if !ok {
+		return nil, p.errorf(tok, "syntax error near %s", describe(p.peek()))
+	}
+	return &callStmt{line: tok.line, call: call}, nil
+}
+
+func (p *parser) doBlock() ([]stmt, error) {
+	if err := p.expect("do"); err != nil {
+		return nil, err
+	}
+	body, err := p.block()
+	if err != nil {
+		return nil, err
+	}
+	return body, p.expect("end")
+}
+
+func (p *parser) ifStatement(line int) (stmt, error) {
+	s := &ifStmt{line: line}
+	for {
+		cond, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("then"); err != nil {
+			return nil, err
+		}
+		body, err := p.block()
+		if err != nil {
+			return nil, err
+		}
+		s.conds = append(s.conds, cond)
+		s.blocks = append(s.blocks, body)
+		if !p.accept("elseif") {
+			break
+		}
+	}
+	if p.accept("else") {
+		body, err := p.block()
+		if err != nil {
+			return nil, err
+		}
+		s.elseBlock = body
+	}
+	return s, p.expect("end")
+}
+
+func (p *parser) forStatement(line int) (stmt, error) {
+	name, err := p.expectName()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("="); err != nil {
+		return nil, err
+	}
+	s := &forStmt{line: line, name: name.text}
+	if s.start, err = p.expression(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(","); err != nil {
+		return nil, err
+	}
+	if s.stop, err = p.expression(); err != nil {
+		return nil, err
+	}
+	if p.accept(",") {
+		if s.step, err = p.expression(); err != nil {
+			return nil, err
+		}
+	}
+	if s.body, err = p.doBlock(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// binaryPrecedence maps binary operators to their precedence, higher binds
+// tighter. The levels follow Lua.
+var binaryPrecedence = map[string]int{
+	"or":  1,
+	"and": 2,
+	"<":   3, ">": 3, "<=": 3, ">=": 3, "~=": 3, "!=": 3, "==": 3,
+	"..": 4,
+	"+":  5, "-": 5,
+	"*": 6, "/": 6, "%": 6,
+}
+
+const unaryPrecedence = 7
+
+func (p *parser) expression() (expr, error) {
+	return p.binary(1)
+}
+
+func (p *parser) binary(minPrecedence int) (expr, error) {
+	defer p.leave()
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		if tok.kind != tokSymbol && tok.kind != tokKeyword {
+			return left, nil
+		}
+		precedence, ok := binaryPrecedence[tok.text]
+		if !ok || precedence < minPrecedence {
+			return left, nil
+		}
+		p.next()
+		// concatenation is right associative, everything else is left
+		nextMin := precedence + 1
+		if tok.text == ".." {
+			nextMin = precedence
+		}
+		right, err := p.binary(nextMin)
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{line: tok.line, op: tok.text, left: left, right: right}
+	}
+}
+
+func (p *parser) unary() (expr, error) {
+	tok := p.peek()
+	if p.accept("not") || p.accept("-") || p.accept("#") {
+		operand, err := p.binary(unaryPrecedence)
+		if err != nil {
+			return nil, err
+		}
+		return &unaryExpr{line: tok.line, op: tok.text, operand: operand}, nil
+	}
+	return p.simple()
+}
+
+func (p *parser) simple() (expr, error) {
+	tok := p.peek()
+	switch tok.kind {
+	case tokNumber:
+		p.next()
+		return &literal{value: tok.num}, nil
+	case tokString:
+		p.next()
+		return &literal{value: tok.text}, nil
+	case tokKeyword:
+		switch tok.text {
+		case "nil":
+			p.next()
+			return &literal{value: nil}, nil
+		case "true", "false":
+			p.next()
+			return &literal{value: tok.text == "true"}, nil
+		}
+	case tokSymbol:
+		if tok.text == "{" {
+			return p.list()
+		}
+	}
+	return p.primary()
+}
+
+func (p *parser) list() (expr, error) {
+	tok := p.next() // '{'
+	l := &listExpr{line: tok.line}
+	for !p.is("}") {
+		item, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		l.items = append(l.items, item)
+		if !p.accept(",") && !p.accept(";") {
+			break
+		}
+	}
+	return l, p.expect("}")
+}
+
+func (p *parser) primary() (expr, error) {
+	tok := p.peek()
+	var e expr
+	switch {
+	case tok.kind == tokName:
+		p.next()
+		if p.is("(") {
+			args, err := p.arguments()
+			if err != nil {
+				return nil, err
+			}
+			e = &callExpr{line: tok.line, name: tok.text, args: args}
+		} else {
+			e = &nameExpr{line: tok.line, name: tok.text}
+		}
+	case p.accept("("):
+		inner, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		e = inner
+	default:
+		return nil, p.errorf(tok, "unexpected %s", describe(tok))
+	}
+
+	for p.is("[") {
+		open := p.next()
+		key, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		e = &indexExpr{line: open.line, object: e, key: key}
+	}
+	return e, nil
+}
+
+func (p *parser) arguments() ([]expr, error) {
+	p.next() // '('
+	var args []expr
+	for !p.is(")") {
+		arg, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if !p.accept(",") {
+			break
+		}
+	}
+	return args, p.expect(")")
+}
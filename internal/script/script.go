@@ -0,0 +1,195 @@
+// Package script implements the small, sandboxed scripting language used by
+// EVAL. Its syntax is a subset of Lua: local variables, if/elseif/else,
+// while and numeric for loops, lists written as {a, b} and indexed from 1,
+// and a fixed set of builtin functions. Scripts cannot define functions or
+// reach anything outside the Env they are given, and every loop iteration and
+// statement counts towards the deadline so runaway scripts are stopped.
+package script
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Value is a script value: nil, bool, float64, string or *List.
+type Value interface{}
+
+// List is the only compound type. Lists are passed by reference.
+type List struct {
+	Items []Value
+}
+
+// ErrTimeout is returned when a script runs past its deadline.
+var ErrTimeout = errors.New("script exceeded its execution time limit")
+
+// ErrMemoryLimit is returned when a script allocates more than it may in one
+// run.
+var ErrMemoryLimit = errors.New("script exceeded its memory limit")
+
+// Error is a compile or runtime error in the script itself.
+type Error struct {
+	Line int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// CallError is raised by call() when the command returns an error reply. The
+// reply is passed through unchanged.
+type CallError struct {
+	Reply string
+}
+
+func (e *CallError) Error() string {
+	return e.Reply
+}
+
+// Env is the environment a script runs in.
+type Env struct {
+	Keys []string
+	Args []string
+	// Call executes a command on behalf of the script and returns its reply.
+	// Error replies are reported as an error whose message is the reply.
+	Call func(args []string) (string, error)
+	// Deadline stops the script once passed. The zero value means no limit.
+	Deadline time.Time
+}
+
+func (e *Env) expired() bool {
+	return !e.Deadline.IsZero() && time.Now().After(e.Deadline)
+}
+
+// Program is a compiled script. It holds no state between runs and may be
+// run concurrently.
+type Program struct {
+	body []stmt
+}
+
+// Compile parses source into a Program.
+func Compile(source string) (*Program, error) {
+	body, err := parse(source)
+	if err != nil {
+		return nil, err
+	}
+	return &Program{body: body}, nil
+}
+
+// Run executes the program and returns the value of its return statement,
+// or nil when it finishes without one.
+func (p *Program) Run(env *Env) (Value, error) {
+	globals := newScope(nil)
+	globals.vars["KEYS"] = stringList(env.Keys)
+	globals.vars["ARGV"] = stringList(env.Args)
+
+	in := &interpreter{env: env}
+	_, value, err := in.execBlock(p.body, newScope(globals))
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func stringList(items []string) *List {
+	list := &List{Items: make([]Value, len(items))}
+	for i, item := range items {
+		list.Items[i] = item
+	}
+	return list
+}
+
+type builtin func(in *interpreter, args []Value) (Value, error)
+
+var builtins = map[string]builtin{
+	"call":     func(in *interpreter, args []Value) (Value, error) { return callCommand(in, args, false) },
+	"pcall":    func(in *interpreter, args []Value) (Value, error) { return callCommand(in, args, true) },
+	"tonumber": builtinToNumber,
+	"tostring": builtinToString,
+	"type":     builtinType,
+	"error":    builtinError,
+}
+
+// callCommand runs a command through the environment. Replies of "nil" are
+// converted to nil so scripts can test for missing keys directly. When
+// protected is set, error replies are returned as strings instead of
+// aborting the script.
+func callCommand(in *interpreter, args []Value, protected bool) (Value, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("call requires at least the command name")
+	}
+	if in.env.Call == nil {
+		return nil, fmt.Errorf("commands cannot be called from this script")
+	}
+	command := make([]string, len(args))
+	for i, arg := range args {
+		s, ok := ToString(arg)
+		if !ok || arg == nil {
+			return nil, fmt.Errorf("bad argument #%d to call, expected a string or number but got %s", i+1, TypeName(arg))
+		}
+		command[i] = s
+	}
+
+	reply, err := in.env.Call(command)
+	if err != nil {
+		if protected {
+			return err.Error(), nil
+		}
+		return nil, &CallError{Reply: err.Error()}
+	}
+	if err := in.alloc(len(reply)); err != nil {
+		return nil, err
+	}
+	if reply == "nil" {
+		return nil, nil
+	}
+	return reply, nil
+}
+
+func builtinToNumber(in *interpreter, args []Value) (Value, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("tonumber expects exactly one argument")
+	}
+	if n, ok := ToNumber(args[0]); ok {
+		return n, nil
+	}
+	return nil, nil
+}
+
+func builtinToString(in *interpreter, args []Value) (Value, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("tostring expects exactly one argument")
+	}
+	if list, ok := args[0].(*List); ok {
+		parts := make([]string, len(list.Items))
+		size := 2
+		for i, item := range list.Items {
+			parts[i], _ = ToString(item)
+			size += len(parts[i]) + 2
+		}
+		// charged before joining, items may repeat one large string
+		if err := in.alloc(size); err != nil {
+			return nil, err
+		}
+		return "{" + strings.Join(parts, ", ") + "}", nil
+	}
+	s, _ := ToString(args[0])
+	return s, nil
+}
+
+func builtinType(in *interpreter, args []Value) (Value, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("type expects exactly one argument")
+	}
+	return TypeName(args[0]), nil
+}
+
+func builtinError(in *interpreter, args []Value) (Value, error) {
+	msg := "error"
+	if len(args) > 0 {
+		msg, _ = ToString(args[0])
+	}
+	return nil, errors.New(msg)
+}
@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"errors"
+	"fmt"
 	"strings"
 )
 
@@ -19,6 +21,86 @@ func MatchPattern(key, pattern string) bool {
 	return strings.Contains(key, pattern)
 }
 
+// GlobMatch reports whether s matches the glob-style pattern. '*' matches any
+// sequence of characters, '?' matches a single character, [abc], [^abc] and
+// [a-z] match character classes and '\' escapes the next character. Unlike
+// MatchPattern the whole string has to match.
+func GlobMatch(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if GlobMatch(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+			s = s[1:]
+			pattern = pattern[1:]
+		case '[':
+			if len(s) == 0 {
+				return false
+			}
+			end := strings.IndexByte(pattern[1:], ']')
+			if end < 0 {
+				// no closing bracket, match '[' literally
+				if s[0] != '[' {
+					return false
+				}
+				s = s[1:]
+				pattern = pattern[1:]
+				continue
+			}
+			class := pattern[1 : end+1]
+			negate := len(class) > 0 && class[0] == '^'
+			if negate {
+				class = class[1:]
+			}
+			if matchClass(class, s[0]) == negate {
+				return false
+			}
+			s = s[1:]
+			pattern = pattern[end+2:]
+		default:
+			if pattern[0] == '\\' && len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			if len(s) == 0 || s[0] != pattern[0] {
+				return false
+			}
+			s = s[1:]
+			pattern = pattern[1:]
+		}
+	}
+	return len(s) == 0
+}
+
+func matchClass(class string, c byte) bool {
+	for i := 0; i < len(class); i++ {
+		if i+2 < len(class) && class[i+1] == '-' {
+			if class[i] <= c && c <= class[i+2] {
+				return true
+			}
+			i += 2
+			continue
+		}
+		if class[i] == c {
+			return true
+		}
+	}
+	return false
+}
+
 // Helper function to send multiline responses to clients.
 // This function replaces newline characters with '<br>' as the client expects a single line response.
 func FormatMultilineResponse(response string) string {
@@ -36,3 +118,140 @@ func ParseServerResponse(response string) string {
 	}
 	return response
 }
+
+// Helper function to split a command line into arguments.
+// Arguments are separated by whitespace and may be wrapped in double quotes,
+// which understand the escapes \" \\ \n \r \t and \xHH, or in single quotes,
+// which only understand \'. This allows values containing spaces to be sent.
+func SplitArgs(line string) ([]string, error) {
+	var args []string
+	i := 0
+	for {
+		for i < len(line) && isSpace(line[i]) {
+			i++
+		}
+		if i >= len(line) {
+			return args, nil
+		}
+
+		var current strings.Builder
+		switch quote := line[i]; quote {
+		case '"', '\'':
+			i++
+			closed := false
+			for i < len(line) {
+				c := line[i]
+				if c == '\\' && i+1 < len(line) {
+					if quote == '\'' {
+						if line[i+1] == '\'' {
+							current.WriteByte('\'')
+							i += 2
+							continue
+						}
+						current.WriteByte(c)
+						i++
+						continue
+					}
+					if line[i+1] == 'x' && i+3 < len(line) && isHex(line[i+2]) && isHex(line[i+3]) {
+						current.WriteByte(hexValue(line[i+2])<<4 | hexValue(line[i+3]))
+						i += 4
+						continue
+					}
+					switch line[i+1] {
+					case 'n':
+						current.WriteByte('\n')
+					case 'r':
+						current.WriteByte('\r')
+					case 't':
+						current.WriteByte('\t')
+					default:
+						current.WriteByte(line[i+1])
+					}
+					i += 2
+					continue
+				}
+				if c == quote {
+					closed = true
+					i++
+					break
+				}
+				current.WriteByte(c)
+				i++
+			}
+			if !closed {
+				return nil, errors.New("unbalanced quotes in request")
+			}
+			if i < len(line) && !isSpace(line[i]) {
+				return nil, errors.New("closing quote must be followed by a space")
+			}
+		default:
+			for i < len(line) && !isSpace(line[i]) {
+				current.WriteByte(line[i])
+				i++
+			}
+		}
+		args = append(args, current.String())
+	}
+}
+
+// Helper function to join arguments into a command line that SplitArgs can
+// parse back, quoting arguments that would otherwise be split or altered.
+func JoinArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = quoteArg(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+func quoteArg(arg string) string {
+	needsQuotes := arg == "" || arg[0] == '"' || arg[0] == '\''
+	for i := 0; i < len(arg) && !needsQuotes; i++ {
+		if isSpace(arg[i]) || arg[i] < 0x20 || arg[i] == 0x7f {
+			needsQuotes = true
+		}
+	}
+	if !needsQuotes {
+		return arg
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(arg); i++ {
+		switch c := arg[i]; {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c == '\r':
+			b.WriteString(`\r`)
+		case c == '\t':
+			b.WriteString(`\t`)
+		case c < 0x20 || c == 0x7f:
+			fmt.Fprintf(&b, `\x%02x`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f'
+}
+
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func hexValue(c byte) byte {
+	switch {
+	case c >= 'a':
+		return c - 'a' + 10
+	case c >= 'A':
+		return c - 'A' + 10
+	}
+	return c - '0'
+}
@@ -3,8 +3,10 @@ package client
 import (
 	"bufio"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"net"
+	"os"
 	"sort"
 	"strings"
 	"sync"
@@ -15,10 +17,75 @@ import (
 )
 
 type Client struct {
-	conn        net.Conn
-	reader      *bufio.Reader
-	password    string
-	authEnabled bool
+	conn    net.Conn
+	reader  *bufio.Reader
+	address string
+	options Options
+}
+
+// Options configure how a client connects and authenticates.
+type Options struct {
+	// Username is the ACL user to authenticate as, the default user when
+	// empty.
+	Username    string
+	Password    string
+	AuthEnabled bool
+	// Network is "tcp", the default, or "unix" to connect to the Unix socket
+	// at the address.
+	Network string
+	// Plaintext connects without TLS, to plaintext TCP listeners. Unix
+	// sockets are always plaintext.
+	Plaintext bool
+	TLS       TLSOptions
+}
+
+// TLSOptions configure the TLS connection. The server certificate is always
+// verified unless InsecureSkipVerify is set.
+type TLSOptions struct {
+	// CAFile is a PEM bundle of the CAs trusted to sign the server
+	// certificate. The system roots are used when empty.
+	CAFile string
+	// ServerName is checked against the server certificate, the host of the
+	// address is used when empty.
+	ServerName string
+	// CertFile and KeyFile are the client certificate and key presented to
+	// servers that require mutual TLS.
+	CertFile           string
+	KeyFile            string
+	InsecureSkipVerify bool
+}
+
+// Config builds the tls.Config used to connect to address.
+func (o TLSOptions) Config(address string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         o.ServerName,
+		InsecureSkipVerify: o.InsecureSkipVerify,
+	}
+	if tlsConfig.ServerName == "" {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q: %w", address, err)
+		}
+		tlsConfig.ServerName = host
+	}
+	if o.CAFile != "" {
+		data, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", o.CAFile)
+		}
+	}
+	if o.CertFile != "" || o.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
 }
 
 type ControlFlow int
@@ -37,21 +104,28 @@ type BenchmarkResult struct {
 	P99 time.Duration
 }
 
+// NewClient connects to address with the default options, verifying the
+// server certificate against the system roots.
 func NewClient(address, password string, authEnabled bool) (*Client, error) {
-	conn, err := tls.Dial("tcp", address, &tls.Config{InsecureSkipVerify: true})
+	return NewClientWithOptions(address, Options{Password: password, AuthEnabled: authEnabled})
+}
+
+func NewClientWithOptions(address string, options Options) (*Client, error) {
+	conn, err := dial(address, options)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to server: %w", err)
 	}
 
 	client := &Client{
-		conn:        conn,
-		reader:      bufio.NewReader(conn),
-		password:    password,
-		authEnabled: authEnabled,
+		conn:    conn,
+		reader:  bufio.NewReader(conn),
+		address: address,
+		options: options,
 	}
 
-	if authEnabled {
+	if options.AuthEnabled {
 		if err := client.authenticate(); err != nil {
+			conn.Close()
 			return nil, err
 		}
 	}
@@ -59,9 +133,28 @@ func NewClient(address, password string, authEnabled bool) (*Client, error) {
 	return client, nil
 }
 
+func dial(address string, options Options) (net.Conn, error) {
+	network := options.Network
+	if network == "" {
+		network = "tcp"
+	}
+	if options.Plaintext || network == "unix" {
+		return net.Dial(network, address)
+	}
+	tlsConfig, err := options.TLS.Config(address)
+	if err != nil {
+		return nil, err
+	}
+	return tls.Dial(network, address, tlsConfig)
+}
+
 func (c *Client) authenticate() error {
 	authCommand := protocol.AuthCommand{}
-	response, err := c.SendCommand(fmt.Sprintf("%s %s", authCommand.GetCommandInfo().Command, c.password))
+	args := []string{authCommand.GetCommandInfo().Command, c.options.Password}
+	if c.options.Username != "" {
+		args = []string{authCommand.GetCommandInfo().Command, c.options.Username, c.options.Password}
+	}
+	response, err := c.SendCommand(utils.JoinArgs(args))
 	if err != nil {
 		return fmt.Errorf("failed to authenticate: %w", err)
 	}
@@ -102,7 +195,7 @@ func (c *Client) Benchmark(commands []string, clients, iterations int) (map[stri
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			client, err := NewClient(c.conn.RemoteAddr().String(), c.password, c.authEnabled)
+			client, err := NewClientWithOptions(c.address, c.options)
 			if err != nil {
 				return
 			}
@@ -0,0 +1,181 @@
+package client
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Info is the reply to INFO. Fields of sections that were not requested are
+// left zero.
+type Info struct {
+	// Server
+	GoVersion       string `info:"go_version"`
+	OS              string `info:"os"`
+	Arch            string `info:"arch"`
+	ProcessID       int64  `info:"process_id"`
+	UptimeInSeconds int64  `info:"uptime_in_seconds"`
+
+	// Clients
+	ConnectedClients int64 `info:"connected_clients"`
+	// MaxClients is the max_connections setting, 0 when unlimited
+	MaxClients int64 `info:"max_clients"`
+
+	// Memory
+	UsedMemory    int64 `info:"used_memory"`
+	UsedMemorySys int64 `info:"used_memory_sys"`
+	NumGC         int64 `info:"num_gc"`
+
+	// Persistence
+	AOFEnabled         bool   `info:"aof_enabled"`
+	AOFCurrentSize     int64  `info:"aof_current_size"`
+	AOFLastWriteStatus string `info:"aof_last_write_status"`
+
+	// Stats
+	TotalConnectionsReceived int64 `info:"total_connections_received"`
+	RejectedConnections      int64 `info:"rejected_connections"`
+	TotalCommandsProcessed   int64 `info:"total_commands_processed"`
+	RejectedCommands         int64 `info:"rejected_commands"`
+	TotalNetInputBytes       int64 `info:"total_net_input_bytes"`
+	TotalNetOutputBytes      int64 `info:"total_net_output_bytes"`
+	KeyspaceHits             int64 `info:"keyspace_hits"`
+	KeyspaceMisses           int64 `info:"keyspace_misses"`
+	ExpiredKeys              int64 `info:"expired_keys"`
+	AuthFailures             int64 `info:"auth_failures"`
+	RateLimitedCommands      int64 `info:"rate_limited_commands"`
+
+	// Commands holds the commandstats section by lowercase command name.
+	Commands map[string]CommandStats
+	// Keyspace holds the keyspace section by database, such as "db0".
+	// Databases without keys are omitted.
+	Keyspace map[string]KeyspaceStats
+	// Fields holds every field of the reply as sent, including the ones
+	// above.
+	Fields map[string]string
+}
+
+// CommandStats are the calls of one command reported by INFO commandstats.
+type CommandStats struct {
+	Calls         int64   `info:"calls"`
+	Usec          int64   `info:"usec"`
+	UsecPerCall   float64 `info:"usec_per_call"`
+	RejectedCalls int64   `info:"rejected_calls"`
+	FailedCalls   int64   `info:"failed_calls"`
+}
+
+// KeyspaceStats are the keys of one database reported by INFO keyspace.
+type KeyspaceStats struct {
+	Keys    int64 `info:"keys"`
+	Expires int64 `info:"expires"`
+}
+
+// Info sends INFO for the given sections, all of them when none are given,
+// and parses the reply.
+func (c *Client) Info(sections ...string) (*Info, error) {
+	response, err := c.SendCommand(strings.Join(append([]string{"INFO"}, sections...), " "))
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(response, "ERR") {
+		return nil, fmt.Errorf("INFO failed: %s", response)
+	}
+	return ParseInfo(response)
+}
+
+// ParseInfo parses an INFO reply made of "# Section" headers and name:value
+// lines.
+func ParseInfo(response string) (*Info, error) {
+	info := &Info{
+		Commands: make(map[string]CommandStats),
+		Keyspace: make(map[string]KeyspaceStats),
+		Fields:   make(map[string]string),
+	}
+	fields := infoFields(reflect.ValueOf(info).Elem())
+	for _, line := range strings.Split(response, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid INFO line %q", line)
+		}
+		info.Fields[name] = value
+
+		switch {
+		case strings.HasPrefix(name, "cmdstat_"):
+			var stats CommandStats
+			if err := parseInfoValues(value, &stats); err != nil {
+				return nil, fmt.Errorf("invalid INFO field %s: %w", name, err)
+			}
+			info.Commands[strings.TrimPrefix(name, "cmdstat_")] = stats
+		case strings.HasPrefix(name, "db"):
+			var stats KeyspaceStats
+			if err := parseInfoValues(value, &stats); err != nil {
+				return nil, fmt.Errorf("invalid INFO field %s: %w", name, err)
+			}
+			info.Keyspace[name] = stats
+		default:
+			// fields unknown to this version of the client are only kept in
+			// Fields
+			if field, ok := fields[name]; ok {
+				if err := setInfoField(field, value); err != nil {
+					return nil, fmt.Errorf("invalid INFO field %s: %w", name, err)
+				}
+			}
+		}
+	}
+	return info, nil
+}
+
+// parseInfoValues parses comma separated name=value pairs into the tagged
+// fields of target.
+func parseInfoValues(value string, target interface{}) error {
+	fields := infoFields(reflect.ValueOf(target).Elem())
+	for _, pair := range strings.Split(value, ",") {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("invalid value %q", pair)
+		}
+		if field, ok := fields[name]; ok {
+			if err := setInfoField(field, value); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// infoFields maps the info tags of the struct v to its fields.
+func infoFields(v reflect.Value) map[string]reflect.Value {
+	fields := make(map[string]reflect.Value)
+	for i := 0; i < v.NumField(); i++ {
+		if name := v.Type().Field(i).Tag.Get("info"); name != "" {
+			fields[name] = v.Field(i)
+		}
+	}
+	return fields
+}
+
+func setInfoField(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.Bool:
+		field.SetBool(value == "1")
+	}
+	return nil
+}
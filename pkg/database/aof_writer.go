@@ -5,11 +5,22 @@ import (
 	"os"
 	"sync"
 	"time"
+
+	"github.com/yashs662/SynchroDB/internal/latency"
+	"github.com/yashs662/SynchroDB/internal/metrics"
+)
+
+var (
+	aofWriteDuration = metrics.NewHistogram("synchrodb_aof_write_duration_seconds", "Time spent appending entries to the AOF file", metrics.LatencyBuckets)
+	aofWriteErrors   = metrics.NewCounter("synchrodb_aof_write_errors_total", "Failed writes to the AOF file")
 )
 
 type AOFWriter struct {
 	file *os.File
 	mu   sync.Mutex
+	// size is the file size in bytes and lastErr the result of the last write
+	size    int64
+	lastErr error
 }
 
 func NewAOFWriter(filepath string) (*AOFWriter, error) {
@@ -17,17 +28,44 @@ func NewAOFWriter(filepath string) (*AOFWriter, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &AOFWriter{file: file}, nil
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &AOFWriter{file: file, size: info.Size()}, nil
 }
 
 func (aof *AOFWriter) Write(command string) error {
 	aof.mu.Lock()
 	defer aof.mu.Unlock()
-	timestamp := time.Now().Unix()
-	_, err := aof.file.WriteString(fmt.Sprintf("%d %s\n", timestamp, command))
+	start := time.Now()
+	n, err := aof.file.WriteString(fmt.Sprintf("%d %s\n", start.Unix(), command))
+	duration := time.Since(start)
+	aofWriteDuration.ObserveDuration(duration)
+	latency.Record(latency.EventAOFWrite, duration)
+	if err != nil {
+		aofWriteErrors.Inc()
+	}
+	aof.size += int64(n)
+	aof.lastErr = err
 	return err
 }
 
+// Size returns the size of the AOF file in bytes.
+func (aof *AOFWriter) Size() int64 {
+	aof.mu.Lock()
+	defer aof.mu.Unlock()
+	return aof.size
+}
+
+// LastError returns the error of the last write, nil when it succeeded.
+func (aof *AOFWriter) LastError() error {
+	aof.mu.Lock()
+	defer aof.mu.Unlock()
+	return aof.lastErr
+}
+
 func (aof *AOFWriter) Close() error {
 	return aof.file.Close()
 }
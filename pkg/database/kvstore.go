@@ -2,7 +2,9 @@ package database
 
 import (
 	"bufio"
-	"fmt"
+	"errors"
+	"math"
+	"math/rand/v2"
 	"net"
 	"os"
 	"strconv"
@@ -10,7 +12,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/yashs662/SynchroDB/internal/latency"
 	"github.com/yashs662/SynchroDB/internal/logger"
+	"github.com/yashs662/SynchroDB/internal/metrics"
 	"github.com/yashs662/SynchroDB/internal/utils"
 )
 
@@ -35,11 +39,36 @@ func (r *CommandRegistry) Get(name string) (Command, bool) {
 	return cmd, exists
 }
 
+var (
+	keyspaceHits   = metrics.NewCounter("synchrodb_keyspace_hits_total", "Successful key lookups by read commands")
+	keyspaceMisses = metrics.NewCounter("synchrodb_keyspace_misses_total", "Key lookups by read commands that found no key")
+	expiredKeys    = metrics.NewCounter("synchrodb_expired_keys_total", "Keys deleted because their expiration passed")
+)
+
+// Stats are the keyspace counters reported by INFO.
+type Stats struct {
+	KeyspaceHits   int64
+	KeyspaceMisses int64
+	ExpiredKeys    int64
+}
+
 type KVStore struct {
+	// mu serialises writers so that compound operations such as MSET or
+	// SET with NX appear atomic to other clients. Single key reads stay
+	// lock free through the sync.Maps.
+	mu          sync.RWMutex
 	data        sync.Map
 	expirations sync.Map
 }
 
+// SetOptions controls the behaviour of SetWithOptions.
+type SetOptions struct {
+	NX       bool      // only set the key if it does not already exist
+	XX       bool      // only set the key if it already exists
+	KeepTTL  bool      // retain the time to live of the existing key
+	ExpireAt time.Time // absolute expiration, the zero value means none
+}
+
 func NewKVStore() *KVStore {
 	store := &KVStore{}
 	go store.cleanupExpiredKeys()
@@ -47,24 +76,87 @@ func NewKVStore() *KVStore {
 }
 
 func (store *KVStore) Set(key, value string) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
 	store.data.Store(key, value)
 	store.expirations.Delete(key) // Remove expiration if it exists
 }
 
 func (store *KVStore) SetWithTTL(key, value string, ttl time.Duration) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
 	store.data.Store(key, value)
 	store.expirations.Store(key, time.Now().Add(ttl))
 }
 
+// SetWithOptions sets key to value honouring the NX/XX conditions and the
+// expiration settings in opts. It returns the previous value, whether the key
+// existed before the call and whether the value was actually written.
+func (store *KVStore) SetWithOptions(key, value string, opts SetOptions) (string, bool, bool) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	old, existed := store.load(key)
+	if (opts.NX && existed) || (opts.XX && !existed) {
+		return old, existed, false
+	}
+
+	store.data.Store(key, value)
+	switch {
+	case !opts.ExpireAt.IsZero():
+		store.expirations.Store(key, opts.ExpireAt)
+	case opts.KeepTTL && existed:
+		// leave the current expiration untouched
+	default:
+		store.expirations.Delete(key)
+	}
+	return old, existed, true
+}
+
 func (store *KVStore) SetExpire(key string, ttl int) bool {
-	if _, exists := store.data.Load(key); exists {
-		store.expirations.Store(key, time.Now().Add(time.Duration(ttl)*time.Second))
+	return store.ExpireAt(key, time.Now().Add(time.Duration(ttl)*time.Second))
+}
+
+// ExpireAt sets the absolute expiration time of an existing key.
+func (store *KVStore) ExpireAt(key string, expireAt time.Time) bool {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if _, exists := store.load(key); exists {
+		store.expirations.Store(key, expireAt)
 		return true
 	}
 	return false
 }
 
+// Persist removes the expiration of key. It returns false when the key does
+// not exist or has no expiration.
+func (store *KVStore) Persist(key string) bool {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if _, exists := store.load(key); !exists {
+		return false
+	}
+	_, hadExpiration := store.expirations.LoadAndDelete(key)
+	return hadExpiration
+}
+
 func (store *KVStore) Get(key string) (string, bool) {
+	return store.lookup(key)
+}
+
+// lookup is load for read commands, counting keyspace hits and misses.
+func (store *KVStore) lookup(key string) (string, bool) {
+	value, exists := store.load(key)
+	if exists {
+		keyspaceHits.Inc()
+	} else {
+		keyspaceMisses.Inc()
+	}
+	return value, exists
+}
+
+// load returns the value stored at key, treating expired keys as missing.
+func (store *KVStore) load(key string) (string, bool) {
 	if exp, exists := store.expirations.Load(key); exists {
 		if time.Now().After(exp.(time.Time)) {
 			return "", false // Key has expired
@@ -78,12 +170,132 @@ func (store *KVStore) Get(key string) (string, bool) {
 	return value.(string), true
 }
 
+// delete removes key and its expiration. Callers must hold store.mu.
+func (store *KVStore) delete(key string) {
+	store.data.Delete(key)
+	store.expirations.Delete(key)
+}
+
+// MSet sets all the given keys atomically, clearing any existing expirations.
+func (store *KVStore) MSet(pairs map[string]string) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	for key, value := range pairs {
+		store.data.Store(key, value)
+		store.expirations.Delete(key)
+	}
+}
+
+// MGet returns the values of all the given keys from a consistent view of the
+// store. found[i] reports whether keys[i] exists.
+func (store *KVStore) MGet(keys []string) (values []string, found []bool) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+	values = make([]string, len(keys))
+	found = make([]bool, len(keys))
+	for i, key := range keys {
+		values[i], found[i] = store.lookup(key)
+	}
+	return values, found
+}
+
+// Append appends value to the string stored at key, creating the key if it
+// does not exist, and returns the length of the resulting string.
+func (store *KVStore) Append(key, value string) int {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	old, existed := store.load(key)
+	if !existed {
+		store.expirations.Delete(key)
+	}
+	newValue := old + value
+	store.data.Store(key, newValue)
+	return len(newValue)
+}
+
+// ErrTooLarge is returned when a string would grow past maxStringLength.
+var ErrTooLarge = errors.New("string exceeds maximum allowed size")
+
+// maxStringLength bounds the strings SETRANGE may create, so a single command
+// cannot allocate an arbitrarily large value.
+const maxStringLength = 512 * 1024 * 1024
+
+// SetRange overwrites part of the string stored at key starting at offset,
+// padding with zero bytes when the string is shorter than offset. It returns
+// the length of the resulting string, or ErrTooLarge when it would be longer
+// than maxStringLength.
+func (store *KVStore) SetRange(key string, offset int, value string) (int, error) {
+	if offset < 0 || offset > maxStringLength-len(value) {
+		return 0, ErrTooLarge
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	old, existed := store.load(key)
+	if !existed {
+		store.expirations.Delete(key)
+		if value == "" {
+			return 0, nil
+		}
+	}
+	if value == "" {
+		return len(old), nil
+	}
+
+	buf := []byte(old)
+	if end := offset + len(value); end > len(buf) {
+		buf = append(buf, make([]byte, end-len(buf))...)
+	}
+	copy(buf[offset:], value)
+	store.data.Store(key, string(buf))
+	return len(buf), nil
+}
+
+// GetDel returns the value stored at key and deletes the key.
+func (store *KVStore) GetDel(key string) (string, bool) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	value, exists := store.lookup(key)
+	if exists {
+		store.delete(key)
+	}
+	return value, exists
+}
+
+// GetEx returns the value stored at key and updates its expiration. A zero
+// expireAt leaves the expiration untouched unless persist is set, in which
+// case the expiration is removed.
+func (store *KVStore) GetEx(key string, expireAt time.Time, persist bool) (string, bool) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	value, exists := store.lookup(key)
+	if !exists {
+		return "", false
+	}
+	if !expireAt.IsZero() {
+		store.expirations.Store(key, expireAt)
+	} else if persist {
+		store.expirations.Delete(key)
+	}
+	return value, true
+}
+
 func (store *KVStore) TTL(key string) int {
+	ttl := store.PTTL(key)
+	if ttl < 0 {
+		return int(ttl)
+	}
+	return int((ttl + 500) / 1000) // round to the nearest second
+}
+
+// PTTL returns the remaining time to live of key in milliseconds, -1 if the
+// key has no expiration and -2 if it does not exist.
+func (store *KVStore) PTTL(key string) int64 {
 	if exp, exists := store.expirations.Load(key); exists {
-		if time.Now().After(exp.(time.Time)) {
+		remaining := time.Until(exp.(time.Time))
+		if remaining < 0 {
 			return -2 // Key has expired
 		}
-		return int(time.Until(exp.(time.Time)).Seconds())
+		return remaining.Milliseconds()
 	}
 
 	if _, exists := store.data.Load(key); !exists {
@@ -93,26 +305,106 @@ func (store *KVStore) TTL(key string) int {
 	}
 }
 
+func (store *KVStore) Exists(key string) bool {
+	_, exists := store.load(key)
+	return exists
+}
+
 func (store *KVStore) Del(key string) bool {
-	if _, exists := store.data.Load(key); exists {
-		store.data.Delete(key)
-		store.expirations.Delete(key)
+	return store.DelKeys([]string{key}) == 1
+}
+
+// DelKeys atomically deletes all the given keys and returns how many of them
+// existed.
+func (store *KVStore) DelKeys(keys []string) int {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	deleted := 0
+	for _, key := range keys {
+		if _, exists := store.load(key); exists {
+			deleted++
+		}
+		store.delete(key)
+	}
+	return deleted
+}
+
+// Rename moves the value and expiration of src to dst, overwriting dst. It
+// returns false when src does not exist.
+func (store *KVStore) Rename(src, dst string) bool {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	value, exists := store.load(src)
+	if !exists {
+		return false
+	}
+	if src == dst {
 		return true
 	}
-	return false
+	exp, hasExpiration := store.expirations.Load(src)
+	store.delete(src)
+	store.data.Store(dst, value)
+	if hasExpiration {
+		store.expirations.Store(dst, exp)
+	} else {
+		store.expirations.Delete(dst)
+	}
+	return true
+}
+
+// Copy copies the value and expiration of src to dst. Unless replace is set,
+// nothing is copied when dst already exists. It reports whether a copy was
+// made.
+func (store *KVStore) Copy(src, dst string, replace bool) bool {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	value, exists := store.load(src)
+	if !exists || src == dst {
+		return false
+	}
+	if _, dstExists := store.load(dst); dstExists && !replace {
+		return false
+	}
+	store.data.Store(dst, value)
+	if exp, hasExpiration := store.expirations.Load(src); hasExpiration {
+		store.expirations.Store(dst, exp)
+	} else {
+		store.expirations.Delete(dst)
+	}
+	return true
+}
+
+// RandomKey returns a key chosen uniformly at random among the live keys.
+func (store *KVStore) RandomKey() (string, bool) {
+	chosen, seen := "", 0
+	store.data.Range(func(key, value interface{}) bool {
+		if _, exists := store.load(key.(string)); !exists {
+			return true
+		}
+		seen++
+		// reservoir sampling keeps every key equally likely in a single pass
+		if rand.IntN(seen) == 0 {
+			chosen = key.(string)
+		}
+		return true
+	})
+	return chosen, seen > 0
 }
 
 func (store *KVStore) cleanupExpiredKeys() {
 	for {
 		time.Sleep(1 * time.Second) // Run every second
 		now := time.Now()
+		store.mu.Lock()
 		store.expirations.Range(func(key, exp interface{}) bool {
 			if now.After(exp.(time.Time)) {
-				store.data.Delete(key)
-				store.expirations.Delete(key)
+				store.delete(key.(string))
+				expiredKeys.Inc()
 			}
 			return true
 		})
+		store.mu.Unlock()
+		latency.Record(latency.EventExpireCycle, time.Since(now))
 	}
 }
 
@@ -127,15 +419,14 @@ func (store *KVStore) LoadFromAOF(filepath string, commandRegistry *CommandRegis
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		line := scanner.Text()
-		parts := strings.Fields(line)
-		if len(parts) < 2 {
+		parts, err := utils.SplitArgs(line)
+		if err != nil || len(parts) < 2 {
 			logger.Debugf("Skipping Malformed line in AOF: %s", line)
 			continue // Malformed line
 		}
 
 		// Parse timestamp and command
-		_, err := strconv.ParseInt(parts[0], 10, 64)
-		if err != nil {
+		if _, err := strconv.ParseInt(parts[0], 10, 64); err != nil {
 			logger.Debugf("Skipping Invalid timestamp in AOF: %s", line)
 			continue // Skip invalid entries
 		}
@@ -159,9 +450,39 @@ func (store *KVStore) LoadFromAOF(filepath string, commandRegistry *CommandRegis
 	return scanner.Err()
 }
 
+// Stats returns the keyspace counters. They count the lookups and expirations
+// of every store in the process.
+func (store *KVStore) Stats() Stats {
+	return Stats{
+		KeyspaceHits:   keyspaceHits.Value(),
+		KeyspaceMisses: keyspaceMisses.Value(),
+		ExpiredKeys:    expiredKeys.Value(),
+	}
+}
+
+// KeyCount returns the number of live keys and how many of them have an
+// expiration.
+func (store *KVStore) KeyCount() (keys, expires int) {
+	now := time.Now()
+	store.data.Range(func(key, value interface{}) bool {
+		exp, hasExpiration := store.expirations.Load(key)
+		if hasExpiration && now.After(exp.(time.Time)) {
+			return true
+		}
+		keys++
+		if hasExpiration {
+			expires++
+		}
+		return true
+	})
+	return keys, expires
+}
+
 func (store *KVStore) FlushDB() {
-	store.data = sync.Map{}
-	store.expirations = sync.Map{}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.data.Clear()
+	store.expirations.Clear()
 }
 
 func (store *KVStore) Keys(pattern string) []string {
@@ -175,32 +496,83 @@ func (store *KVStore) Keys(pattern string) []string {
 	return keys
 }
 
-func (store *KVStore) Incr(key string) (int, error) {
-	value, exists := store.data.Load(key)
-	if !exists {
-		store.data.Store(key, "1")
-		return 1, nil
+var (
+	ErrNotInteger = errors.New("value is not an integer or out of range")
+	ErrNotFloat   = errors.New("value is not a valid float")
+	ErrOverflow   = errors.New("increment or decrement would overflow")
+	ErrNaNOrInf   = errors.New("increment would produce NaN or Infinity")
+)
+
+func (store *KVStore) Incr(key string) (int64, error) {
+	return store.IncrBy(key, 1)
+}
+
+func (store *KVStore) Decr(key string) (int64, error) {
+	return store.IncrBy(key, -1)
+}
+
+// IncrBy adds delta to the integer stored at key, treating a missing key as 0.
+// The expiration of an existing key is preserved.
+func (store *KVStore) IncrBy(key string, delta int64) (int64, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	var current int64
+	value, exists := store.load(key)
+	if exists {
+		var err error
+		current, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return 0, ErrNotInteger
+		}
 	}
-	intValue, err := strconv.Atoi(value.(string))
-	if err != nil {
-		return 0, fmt.Errorf("value is not an integer")
+	if (delta > 0 && current > math.MaxInt64-delta) || (delta < 0 && current < math.MinInt64-delta) {
+		return 0, ErrOverflow
 	}
-	intValue++
-	store.data.Store(key, strconv.Itoa(intValue))
-	return intValue, nil
+
+	current += delta
+	store.storeNumber(key, strconv.FormatInt(current, 10), exists)
+	return current, nil
 }
 
-func (store *KVStore) Decr(key string) (int, error) {
-	value, exists := store.data.Load(key)
-	if !exists {
-		store.data.Store(key, "-1")
-		return -1, nil
+// IncrByFloat adds delta to the floating point number stored at key, treating
+// a missing key as 0, and returns the new value as it is stored.
+func (store *KVStore) IncrByFloat(key string, delta float64) (string, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	var current float64
+	value, exists := store.load(key)
+	if exists {
+		var err error
+		current, err = strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(current) || math.IsInf(current, 0) {
+			return "", ErrNotFloat
+		}
 	}
-	intValue, err := strconv.Atoi(value.(string))
-	if err != nil {
-		return 0, fmt.Errorf("value is not an integer")
+
+	current += delta
+	if math.IsNaN(current) || math.IsInf(current, 0) {
+		return "", ErrNaNOrInf
 	}
-	intValue--
-	store.data.Store(key, strconv.Itoa(intValue))
-	return intValue, nil
+
+	formatted := FormatFloat(current)
+	store.storeNumber(key, formatted, exists)
+	return formatted, nil
+}
+
+// FormatFloat formats f the way numeric commands store floating point values:
+// the shortest representation that round trips, without an exponent.
+func FormatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// storeNumber writes the result of a numeric command, dropping any stale
+// expiration left behind by a key that had already expired. Callers must hold
+// store.mu.
+func (store *KVStore) storeNumber(key, value string, existed bool) {
+	if !existed {
+		store.expirations.Delete(key)
+	}
+	store.data.Store(key, value)
 }
@@ -0,0 +1,543 @@
+package protocol
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/yashs662/SynchroDB/internal/auth"
+	"github.com/yashs662/SynchroDB/internal/utils"
+)
+
+// defaultUser is the user connections run as until they authenticate as
+// someone else. AUTH with only a password authenticates as this user.
+const defaultUser = "default"
+
+// aclCommandRule allows or denies a category ("@read"), a command ("get") or
+// a subcommand ("acl|whoami"). Rules are evaluated in order and the last one
+// matching a command decides whether it may run.
+type aclCommandRule struct {
+	allow    bool
+	category string
+	command  string
+}
+
+func (r aclCommandRule) matches(info CommandDescription, args []string) bool {
+	if r.category != "" {
+		if r.category == "@all" {
+			return true
+		}
+		for _, category := range info.Categories() {
+			if category == r.category {
+				return true
+			}
+		}
+		return false
+	}
+	name, subcommand, hasSubcommand := strings.Cut(r.command, "|")
+	if !strings.EqualFold(name, info.Command) {
+		return false
+	}
+	return !hasSubcommand || (len(args) > 0 && strings.EqualFold(args[0], subcommand))
+}
+
+func (r aclCommandRule) String() string {
+	sign := "-"
+	if r.allow {
+		sign = "+"
+	}
+	if r.category != "" {
+		return sign + r.category
+	}
+	return sign + r.command
+}
+
+// aclUser is a named user. Passwords are only kept as hashes: hex encoded
+// SHA256 for the ones set with >password, or bcrypt and argon2id hashes taken
+// from the config or the ACL file.
+type aclUser struct {
+	name        string
+	enabled     bool
+	nopass      bool
+	passwords   []string
+	commands    []aclCommandRule
+	allKeys     bool
+	keyPatterns []string
+}
+
+func (u *aclUser) clone() *aclUser {
+	clone := *u
+	clone.passwords = append([]string(nil), u.passwords...)
+	clone.commands = append([]aclCommandRule(nil), u.commands...)
+	clone.keyPatterns = append([]string(nil), u.keyPatterns...)
+	return &clone
+}
+
+func hashPassword(password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+func isPasswordHash(hash string) bool {
+	if auth.IsHash(hash) {
+		return auth.Validate(hash) == nil
+	}
+	if len(hash) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
+
+// normalizeHash lowercases SHA256 hashes. bcrypt and argon2id hashes are case
+// sensitive and kept as they are.
+func normalizeHash(hash string) string {
+	if auth.IsHash(hash) {
+		return hash
+	}
+	return strings.ToLower(hash)
+}
+
+// checkPassword compares password against every stored hash in constant time.
+func (u *aclUser) checkPassword(password string) bool {
+	if u.nopass {
+		return true
+	}
+	hash := []byte(hashPassword(password))
+	match := false
+	for _, stored := range u.passwords {
+		if auth.IsHash(stored) {
+			if ok, err := auth.Verify(stored, password); err == nil && ok {
+				match = true
+			}
+			continue
+		}
+		if subtle.ConstantTimeCompare(hash, []byte(stored)) == 1 {
+			match = true
+		}
+	}
+	return match
+}
+
+func (u *aclUser) addPassword(hash string) {
+	u.nopass = false
+	for _, stored := range u.passwords {
+		if stored == hash {
+			return
+		}
+	}
+	u.passwords = append(u.passwords, hash)
+}
+
+func (u *aclUser) removePassword(hash string) error {
+	for i, stored := range u.passwords {
+		if stored == hash {
+			u.passwords = append(u.passwords[:i], u.passwords[i+1:]...)
+			return nil
+		}
+	}
+	return errors.New("the password to remove does not exist")
+}
+
+func (u *aclUser) canRun(info CommandDescription, args []string) bool {
+	allowed := false
+	for _, rule := range u.commands {
+		if rule.matches(info, args) {
+			allowed = rule.allow
+		}
+	}
+	return allowed
+}
+
+func (u *aclUser) canAccessKey(key string) bool {
+	if u.allKeys {
+		return true
+	}
+	for _, pattern := range u.keyPatterns {
+		if utils.GlobMatch(pattern, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// rules describes the user as the list of rules that recreates it.
+func (u *aclUser) rules() []string {
+	rules := []string{"off"}
+	if u.enabled {
+		rules[0] = "on"
+	}
+	if u.nopass {
+		rules = append(rules, "nopass")
+	}
+	for _, hash := range u.passwords {
+		rules = append(rules, "#"+hash)
+	}
+	if u.allKeys {
+		rules = append(rules, "~*")
+	}
+	for _, pattern := range u.keyPatterns {
+		rules = append(rules, "~"+pattern)
+	}
+	if len(u.commands) == 0 {
+		rules = append(rules, "-@all")
+	}
+	for _, rule := range u.commands {
+		rules = append(rules, rule.String())
+	}
+	return rules
+}
+
+// acl holds the users known to the server.
+type acl struct {
+	mu    sync.RWMutex
+	users map[string]*aclUser
+	// defaultPassword is the password of the default user when the ACL file
+	// does not define it.
+	defaultPassword string
+	// isCommand and isCategory validate the names used in command rules.
+	isCommand  func(name string) bool
+	isCategory func(category string) bool
+}
+
+func newACL(defaultPassword string, isCommand, isCategory func(string) bool) *acl {
+	a := &acl{
+		defaultPassword: defaultPassword,
+		isCommand:       isCommand,
+		isCategory:      isCategory,
+	}
+	a.users = map[string]*aclUser{defaultUser: a.newDefaultUser()}
+	return a
+}
+
+// newDefaultUser returns the default user as configured by the server
+// password, which may be a bcrypt or argon2id hash: allowed to run every
+// command on every key.
+func (a *acl) newDefaultUser() *aclUser {
+	user := &aclUser{
+		name:     defaultUser,
+		enabled:  true,
+		nopass:   a.defaultPassword == "",
+		commands: []aclCommandRule{{allow: true, category: "@all"}},
+		allKeys:  true,
+	}
+	switch {
+	case auth.IsHash(a.defaultPassword):
+		user.passwords = []string{a.defaultPassword}
+	case a.defaultPassword != "":
+		user.passwords = []string{hashPassword(a.defaultPassword)}
+	}
+	return user
+}
+
+// setDefaultPassword replaces the passwords of the default user, as the
+// server password does on startup. An empty password lets anyone
+// authenticate as the default user.
+func (a *acl) setDefaultPassword(password string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.defaultPassword = password
+	if user, ok := a.users[defaultUser]; ok {
+		configured := a.newDefaultUser()
+		user.nopass, user.passwords = configured.nopass, configured.passwords
+	}
+}
+
+func (a *acl) getDefaultPassword() string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.defaultPassword
+}
+
+// applyRule changes user according to a single ACL rule.
+func (a *acl) applyRule(user *aclUser, rule string) error {
+	switch strings.ToLower(rule) {
+	case "on":
+		user.enabled = true
+		return nil
+	case "off":
+		user.enabled = false
+		return nil
+	case "nopass":
+		user.nopass = true
+		user.passwords = nil
+		return nil
+	case "resetpass":
+		user.nopass = false
+		user.passwords = nil
+		return nil
+	case "allkeys", "~*":
+		user.allKeys = true
+		user.keyPatterns = nil
+		return nil
+	case "resetkeys":
+		user.allKeys = false
+		user.keyPatterns = nil
+		return nil
+	case "allcommands", "+@all":
+		user.commands = []aclCommandRule{{allow: true, category: "@all"}}
+		return nil
+	case "nocommands", "-@all":
+		user.commands = nil
+		return nil
+	case "reset":
+		*user = aclUser{name: user.name}
+		return nil
+	}
+
+	if rule == "" {
+		return errors.New("empty rule")
+	}
+	switch value := rule[1:]; rule[0] {
+	case '>':
+		user.addPassword(hashPassword(value))
+	case '<':
+		return user.removePassword(hashPassword(value))
+	case '#':
+		if !isPasswordHash(value) {
+			return fmt.Errorf("'%s' is not a valid SHA256, bcrypt or argon2id password hash", value)
+		}
+		user.addPassword(normalizeHash(value))
+	case '!':
+		return user.removePassword(normalizeHash(value))
+	case '~':
+		if !user.allKeys {
+			user.keyPatterns = append(user.keyPatterns, value)
+		}
+	case '+', '-':
+		command := aclCommandRule{allow: rule[0] == '+'}
+		if strings.HasPrefix(value, "@") {
+			command.category = strings.ToLower(value)
+			if !a.isCategory(command.category) {
+				return fmt.Errorf("unknown command category '%s'", value)
+			}
+		} else {
+			command.command = strings.ToLower(value)
+			name, _, _ := strings.Cut(command.command, "|")
+			if !a.isCommand(name) {
+				return fmt.Errorf("unknown command '%s'", name)
+			}
+		}
+		user.commands = append(user.commands, command)
+	default:
+		return fmt.Errorf("syntax error in ACL rule '%s'", rule)
+	}
+	return nil
+}
+
+// setUser creates or modifies a user. The rules are applied to a copy, so
+// the user is left unchanged if any of them is invalid. New users start
+// disabled, without passwords, commands or keys.
+func (a *acl) setUser(name string, rules []string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.updateUser(a.users, name, rules)
+}
+
+// updateUser applies rules to the named user in users. Callers hold mu when
+// users is a.users.
+func (a *acl) updateUser(users map[string]*aclUser, name string, rules []string) error {
+	user := &aclUser{name: name}
+	if existing, ok := users[name]; ok {
+		user = existing.clone()
+	}
+	for _, rule := range rules {
+		if err := a.applyRule(user, rule); err != nil {
+			return err
+		}
+	}
+	users[name] = user
+	return nil
+}
+
+func (a *acl) getUser(name string) (*aclUser, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	user, ok := a.users[name]
+	if !ok {
+		return nil, false
+	}
+	return user.clone(), true
+}
+
+// deleteUsers removes the named users and returns how many existed.
+func (a *acl) deleteUsers(names []string) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, name := range names {
+		if name == defaultUser {
+			return 0, fmt.Errorf("the '%s' user cannot be removed", defaultUser)
+		}
+	}
+	deleted := 0
+	for _, name := range names {
+		if _, ok := a.users[name]; ok {
+			delete(a.users, name)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// list describes every user, one "user <name> <rules>" line each, sorted by
+// name. This is also the format of the ACL file.
+func (a *acl) list() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	names := make([]string, 0, len(a.users))
+	for name := range a.users {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	lines := make([]string, len(names))
+	for i, name := range names {
+		lines[i] = fmt.Sprintf("user %s %s", name, strings.Join(a.users[name].rules(), " "))
+	}
+	return lines
+}
+
+// authenticate reports whether name is an enabled user accepting password.
+// The password is verified without holding the lock, as bcrypt and argon2id
+// take long enough to hold back every command waiting for ACL changes.
+func (a *acl) authenticate(name, password string) bool {
+	a.mu.RLock()
+	user, ok := a.users[name]
+	var candidate *aclUser
+	if ok {
+		candidate = &aclUser{enabled: user.enabled, nopass: user.nopass, passwords: append([]string(nil), user.passwords...)}
+	} else {
+		candidate = &aclUser{passwords: []string{a.costliestHash()}}
+	}
+	a.mu.RUnlock()
+
+	if !ok {
+		// Verify against a dummy hash of the costliest kind in use, so that
+		// unknown users take as long as wrong passwords and usernames cannot
+		// be told apart by timing
+		if auth.IsHash(candidate.passwords[0]) {
+			dummy, err := auth.DummyHash(candidate.passwords[0])
+			if err != nil {
+				dummy = hashPassword("")
+			}
+			candidate.passwords[0] = dummy
+		}
+		candidate.checkPassword(password)
+		return false
+	}
+	return candidate.checkPassword(password) && candidate.enabled
+}
+
+// costliestHash returns a stored password hash of the costliest kind: an
+// argon2id hash, then a bcrypt one, then a SHA256 one. Callers hold mu.
+func (a *acl) costliestHash() string {
+	costliest := hashPassword("")
+	for _, user := range a.users {
+		for _, stored := range user.passwords {
+			switch {
+			case strings.HasPrefix(stored, "$argon2id$"):
+				return stored
+			case auth.IsHash(stored):
+				costliest = stored
+			}
+		}
+	}
+	return costliest
+}
+
+// userEnabled reports whether name is an existing, enabled user.
+func (a *acl) userEnabled(name string) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	user, ok := a.users[name]
+	return ok && user.enabled
+}
+
+// authorize checks that the user may run the command on the given keys.
+func (a *acl) authorize(name string, info CommandDescription, args []string) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	user, ok := a.users[name]
+	if !ok {
+		return fmt.Errorf("user '%s' no longer exists", name)
+	}
+	if !user.canRun(info, args) {
+		return fmt.Errorf("user '%s' has no permissions to run the '%s' command", name, strings.ToLower(info.Command))
+	}
+	for _, key := range info.Keys(args) {
+		if !user.canAccessKey(key) {
+			return fmt.Errorf("user '%s' has no permissions to access one of the keys used as arguments", name)
+		}
+	}
+	return nil
+}
+
+// load replaces all users with the ones defined in the ACL file at path. Each
+// non-empty line not starting with '#' has the form "user <name> <rule>...".
+// The default user keeps its configured definition unless the file defines
+// it. Nothing changes when the file has errors.
+func (a *acl) load(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open ACL file: %w", err)
+	}
+	defer file.Close()
+
+	users := make(map[string]*aclUser)
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "user" {
+			return fmt.Errorf("%s:%d: lines must have the form 'user <name> <rule>...'", path, lineNumber)
+		}
+		if _, exists := users[fields[1]]; exists {
+			return fmt.Errorf("%s:%d: user '%s' is defined more than once", path, lineNumber, fields[1])
+		}
+		if err := a.updateUser(users, fields[1], fields[2:]); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, lineNumber, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read ACL file: %w", err)
+	}
+	if _, ok := users[defaultUser]; !ok {
+		users[defaultUser] = a.newDefaultUser()
+	}
+
+	a.mu.Lock()
+	a.users = users
+	a.mu.Unlock()
+	return nil
+}
+
+// save writes every user to the ACL file at path, replacing it atomically.
+func (a *acl) save(path string) error {
+	content := strings.Join(a.list(), "\n") + "\n"
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to save ACL file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save ACL file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save ACL file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to save ACL file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package protocol
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/yashs662/SynchroDB/internal/logger"
+	"github.com/yashs662/SynchroDB/internal/utils"
+	"github.com/yashs662/SynchroDB/pkg/database"
+)
+
+// isCategory reports whether any command belongs to category.
+func (s *Server) isCategory(category string) bool {
+	if category == "@all" {
+		return true
+	}
+	for _, command := range s.commands() {
+		for _, c := range command.GetCommandInfo().Categories() {
+			if c == category {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *Server) isCommand(name string) bool {
+	_, _, exists := s.lookupCommand(strings.ToUpper(name))
+	return exists
+}
+
+// checkPermissions returns an error reply when the user of conn may not run
+// the command, and logs the denial.
+func (s *Server) checkPermissions(conn net.Conn, user string, info CommandDescription, args []string) string {
+	if err := s.acl.authorize(user, info, args); err != nil {
+		s.connLogger(conn).Warn("ACL denied", logger.Command(info.Command), logger.User(user), logger.Err(err))
+		return fmt.Sprintf("ERR %v", err)
+	}
+	return ""
+}
+
+type ACLCommand struct {
+	server *Server
+}
+
+func (c *ACLCommand) Execute(conn net.Conn, args []string) string {
+	switch strings.ToUpper(args[0]) {
+	case "SETUSER":
+		if len(args) < 2 {
+			return "ERR wrong number of arguments for 'ACL SETUSER' command"
+		}
+		if err := c.server.acl.setUser(args[1], args[2:]); err != nil {
+			return fmt.Sprintf("ERR error in ACL SETUSER modifier: %v", err)
+		}
+		return "OK"
+	case "GETUSER":
+		if len(args) != 2 {
+			return "ERR wrong number of arguments for 'ACL GETUSER' command"
+		}
+		user, ok := c.server.acl.getUser(args[1])
+		if !ok {
+			return "nil"
+		}
+		return utils.FormatMultilineResponse(describeUser(user))
+	case "DELUSER":
+		if len(args) < 2 {
+			return "ERR wrong number of arguments for 'ACL DELUSER' command"
+		}
+		deleted, err := c.server.acl.deleteUsers(args[1:])
+		if err != nil {
+			return fmt.Sprintf("ERR %v", err)
+		}
+		return strconv.Itoa(deleted)
+	case "LIST":
+		return utils.FormatMultilineResponse(strings.Join(c.server.acl.list(), "\n"))
+	case "WHOAMI":
+		user, _ := c.server.connectionUser(conn)
+		return user
+	case "CAT":
+		return c.categories(args[1:])
+	case "LOAD":
+		if c.server.aclFile == "" {
+			return "ERR no ACL file is configured"
+		}
+		if err := c.server.acl.load(c.server.aclFile); err != nil {
+			return fmt.Sprintf("ERR %v", err)
+		}
+		return "OK"
+	case "SAVE":
+		if c.server.aclFile == "" {
+			return "ERR no ACL file is configured"
+		}
+		if err := c.server.acl.save(c.server.aclFile); err != nil {
+			return fmt.Sprintf("ERR %v", err)
+		}
+		return "OK"
+	}
+	return fmt.Sprintf("ERR unknown subcommand '%s' for 'ACL' command", args[0])
+}
+
+// categories lists every category, or the commands in the given category.
+func (c *ACLCommand) categories(args []string) string {
+	if len(args) > 1 {
+		return "ERR wrong number of arguments for 'ACL CAT' command"
+	}
+	if len(args) == 1 && !strings.HasPrefix(args[0], "@") {
+		args = []string{"@" + args[0]}
+	}
+	seen := make(map[string]bool)
+	var names []string
+	for _, command := range c.server.commands() {
+		info := command.GetCommandInfo()
+		for _, category := range info.Categories() {
+			switch {
+			case len(args) == 0 && !seen[category]:
+				seen[category] = true
+				names = append(names, category)
+			case len(args) == 1 && strings.EqualFold(args[0], category):
+				names = append(names, strings.ToLower(info.Command))
+			}
+		}
+	}
+	if len(args) == 1 && !c.server.isCategory(strings.ToLower(args[0])) {
+		return fmt.Sprintf("ERR unknown category '%s'", args[0])
+	}
+	sort.Strings(names)
+	return utils.FormatMultilineResponse(strings.Join(names, "\n"))
+}
+
+// describeUser formats a user as "flags", "passwords", "keys" and "commands"
+// lines.
+func describeUser(user *aclUser) string {
+	flags := []string{"off"}
+	if user.enabled {
+		flags[0] = "on"
+	}
+	if user.nopass {
+		flags = append(flags, "nopass")
+	}
+	passwords := make([]string, len(user.passwords))
+	for i, hash := range user.passwords {
+		passwords[i] = "#" + hash
+	}
+	var keys []string
+	if user.allKeys {
+		keys = append(keys, "~*")
+	}
+	for _, pattern := range user.keyPatterns {
+		keys = append(keys, "~"+pattern)
+	}
+	commands := []string{"-@all"}
+	if len(user.commands) > 0 {
+		commands = commands[:0]
+		for _, rule := range user.commands {
+			commands = append(commands, rule.String())
+		}
+	}
+	return strings.Join([]string{
+		"flags " + strings.Join(flags, " "),
+		"passwords " + strings.Join(passwords, " "),
+		"keys " + strings.Join(keys, " "),
+		"commands " + strings.Join(commands, " "),
+	}, "\n")
+}
+
+func (c *ACLCommand) Replay(args []string, store *database.KVStore) error {
+	return nil // Users are persisted with ACL SAVE, not the AOF
+}
+
+func (c *ACLCommand) GetCommandInfo() CommandDescription {
+	return CommandDescription{
+		Command:  "ACL",
+		Name:     "Access Control",
+		Syntax:   "ACL SETUSER <username> [<rule> ...] | ACL GETUSER <username> | ACL DELUSER <username> [<username> ...] | ACL LIST | ACL WHOAMI | ACL CAT [<category>] | ACL LOAD | ACL SAVE",
+		HelpText: "Manage users, their passwords and the commands and keys they may use",
+		Arity:    -2,
+		Flags:    []CommandFlag{FlagAdmin, FlagNoScript},
+	}
+}
@@ -0,0 +1,117 @@
+package protocol
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/yashs662/SynchroDB/internal/audit"
+	"github.com/yashs662/SynchroDB/internal/logger"
+)
+
+// auditedCommand returns the name written to the audit log for the command
+// described by info with args, such as "CONFIG SET", and whether it is
+// audited at all.
+func auditedCommand(info CommandDescription, args []string) (string, bool) {
+	switch info.Command {
+	case "AUTH", "FLUSHDB":
+		return info.Command, true
+	case "CONFIG", "ACL", "CLIENT":
+		if len(args) == 0 {
+			return "", false
+		}
+		command := info.Command + " " + strings.ToUpper(args[0])
+		switch command {
+		case "CONFIG SET", "ACL SETUSER", "ACL DELUSER", "ACL LOAD", "ACL SAVE", "CLIENT KILL":
+			return command, true
+		}
+	}
+	return "", false
+}
+
+// audit records a run of an audited command: who ran it, from where, and how
+// it ended. parts are the command name and its arguments, and reply is the
+// reply, or the error that rejected the command when rejected is set.
+// Without an audit log the events are written to the server log.
+func (s *Server) audit(conn net.Conn, user string, info CommandDescription, parts []string, reply string, rejected bool) {
+	command, ok := auditedCommand(info, parts[1:])
+	if !ok {
+		return
+	}
+	// Passwords are redacted and the subcommand is part of the command name
+	args := redactArgs(info, parts)[1:]
+	if strings.Contains(command, " ") {
+		args = args[1:]
+	}
+	// AUTH is recorded with the user it tried to authenticate as, and without
+	// its arguments, which are only that user and the password
+	if info.Command == "AUTH" {
+		user = defaultUser
+		if len(parts) == 3 {
+			user = parts[1]
+		}
+		args = nil
+	}
+
+	event := audit.Event{
+		Time:        time.Now(),
+		User:        user,
+		Addr:        conn.RemoteAddr().String(),
+		TLSIdentity: tlsIdentity(conn),
+		Command:     command,
+		Args:        truncateArgs(args),
+		Outcome:     audit.OutcomeSuccess,
+	}
+	if client := s.client(conn); client != nil {
+		event.ClientID = client.id
+		event.RequestID = fmt.Sprintf("%d-%d", client.id, client.requests.Load())
+	}
+	switch {
+	case rejected:
+		event.Outcome = audit.OutcomeRejected
+		event.Error = strings.TrimPrefix(reply, "ERR ")
+	case strings.HasPrefix(reply, "ERR"):
+		event.Outcome = audit.OutcomeFailure
+		event.Error = strings.TrimPrefix(reply, "ERR ")
+	}
+
+	if s.auditLog == nil {
+		fields := []logger.Field{logger.User(event.User), logger.Command(command), logger.String("outcome", event.Outcome)}
+		if event.TLSIdentity != "" {
+			fields = append(fields, logger.String("tls_identity", event.TLSIdentity))
+		}
+		if len(event.Args) > 0 {
+			fields = append(fields, logger.Any("args", event.Args))
+		}
+		if event.Error != "" {
+			fields = append(fields, logger.String("error", event.Error))
+		}
+		s.connLogger(conn).Info("audit", fields...)
+		return
+	}
+	if err := s.auditLog.Record(event); err != nil {
+		logger.Errorf("%v", err)
+	}
+}
+
+// tlsConnection is implemented by the connections that may carry TLS, such
+// as *tls.Conn and the connections of HTTP gateway requests.
+type tlsConnection interface {
+	ConnectionState() tls.ConnectionState
+}
+
+// tlsIdentity returns the subject of the client certificate of conn, empty
+// for connections without one.
+func tlsIdentity(conn net.Conn) string {
+	tlsConn, ok := conn.(tlsConnection)
+	if !ok {
+		return ""
+	}
+	state := tlsConn.ConnectionState()
+	if len(state.PeerCertificates) == 0 {
+		return ""
+	}
+	return state.PeerCertificates[0].Subject.String()
+}
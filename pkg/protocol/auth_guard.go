@@ -0,0 +1,186 @@
+package protocol
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/yashs662/SynchroDB/internal/config"
+	"github.com/yashs662/SynchroDB/internal/logger"
+	"github.com/yashs662/SynchroDB/internal/metrics"
+)
+
+// Defaults for the AUTH brute-force protection settings.
+const (
+	defaultAuthMaxAttemptsPerConn = config.DefaultAuthMaxAttemptsPerConn
+	defaultAuthBackoffBase        = config.DefaultAuthBackoffBase * time.Millisecond
+	defaultAuthBackoffMax         = config.DefaultAuthBackoffMax * time.Millisecond
+	defaultAuthBanAfterFailures   = config.DefaultAuthBanAfterFailures
+	defaultAuthBanDuration        = config.DefaultAuthBanDuration * time.Second
+)
+
+var authFailures = metrics.NewCounter("synchrodb_auth_failures_total", "Failed and rejected AUTH attempts")
+
+// authFailureRecord tracks the recent failed AUTH attempts from one IP.
+type authFailureRecord struct {
+	failures    int
+	lastFailure time.Time
+	bannedUntil time.Time
+}
+
+// authGuard slows down password guessing. Every failed attempt from an IP
+// makes the next one from that IP wait exponentially longer, and an IP that
+// keeps failing is banned from AUTH for a while. Connections are closed after
+// too many failed attempts of their own.
+type authGuard struct {
+	maxAttemptsPerConn int
+	backoffBase        time.Duration
+	backoffMax         time.Duration
+	banAfterFailures   int
+	banDuration        time.Duration
+
+	mu        sync.Mutex
+	ips       map[string]*authFailureRecord
+	conns     map[net.Conn]int
+	lastPrune time.Time
+}
+
+func newAuthGuard(config *config.Config) *authGuard {
+	g := &authGuard{
+		maxAttemptsPerConn: config.Server.AuthMaxAttemptsPerConn,
+		backoffBase:        time.Duration(config.Server.AuthBackoffBase) * time.Millisecond,
+		backoffMax:         time.Duration(config.Server.AuthBackoffMax) * time.Millisecond,
+		banAfterFailures:   config.Server.AuthBanAfterFailures,
+		banDuration:        time.Duration(config.Server.AuthBanDuration) * time.Second,
+		ips:                make(map[string]*authFailureRecord),
+		conns:              make(map[net.Conn]int),
+	}
+	if g.maxAttemptsPerConn <= 0 {
+		g.maxAttemptsPerConn = defaultAuthMaxAttemptsPerConn
+	}
+	if g.backoffBase <= 0 {
+		g.backoffBase = defaultAuthBackoffBase
+	}
+	if g.backoffMax <= 0 {
+		g.backoffMax = defaultAuthBackoffMax
+	}
+	if g.banAfterFailures <= 0 {
+		g.banAfterFailures = defaultAuthBanAfterFailures
+	}
+	if g.banDuration <= 0 {
+		g.banDuration = defaultAuthBanDuration
+	}
+	return g
+}
+
+// clientIP returns the IP of the remote end of conn. Connections without one,
+// such as Unix sockets, are keyed by the listener they arrived on instead, so
+// reconnecting cannot reset their backoff, ban or rate limit.
+func clientIP(conn net.Conn) string {
+	if host, _, err := net.SplitHostPort(conn.RemoteAddr().String()); err == nil {
+		return host
+	}
+	local := conn.LocalAddr()
+	return local.Network() + ":" + local.String()
+}
+
+// retryAfter returns how long the IP of conn has to wait before it may try to
+// authenticate again, zero when it may try now.
+func (g *authGuard) retryAfter(conn net.Conn) time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	record, ok := g.ips[clientIP(conn)]
+	if !ok {
+		return 0
+	}
+	now := time.Now()
+	if now.Before(record.bannedUntil) {
+		return record.bannedUntil.Sub(now)
+	}
+	next := record.lastFailure.Add(g.backoff(record.failures))
+	if now.Before(next) {
+		return next.Sub(now)
+	}
+	return 0
+}
+
+// backoff doubles the delay for every failure, starting at backoffBase.
+func (g *authGuard) backoff(failures int) time.Duration {
+	delay := g.backoffBase
+	for i := 1; i < failures && delay < g.backoffMax; i++ {
+		delay *= 2
+	}
+	return min(delay, g.backoffMax)
+}
+
+// fail records a failed attempt on conn. It reports whether the connection
+// has used up its attempts and has to be closed.
+func (g *authGuard) fail(conn net.Conn) bool {
+	authFailures.Inc()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	g.prune(now)
+	ip := clientIP(conn)
+	record, ok := g.ips[ip]
+	if !ok {
+		record = &authFailureRecord{}
+		g.ips[ip] = record
+	}
+	record.failures++
+	record.lastFailure = now
+	if record.failures >= g.banAfterFailures && now.After(record.bannedUntil) {
+		record.bannedUntil = now.Add(g.banDuration)
+		logger.Warnf("Banning %s from authenticating for %s after %d failed attempts", ip, g.banDuration, record.failures)
+	}
+
+	g.conns[conn]++
+	return g.conns[conn] >= g.maxAttemptsPerConn
+}
+
+// reject counts an attempt refused because of a backoff or ban. It reports
+// whether the connection has to be closed.
+func (g *authGuard) reject(conn net.Conn) bool {
+	authFailures.Inc()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.conns[conn]++
+	return g.conns[conn] >= g.maxAttemptsPerConn
+}
+
+// succeed clears the failures of conn and its IP.
+func (g *authGuard) succeed(conn net.Conn) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.ips, clientIP(conn))
+	delete(g.conns, conn)
+}
+
+// forget drops the per-connection counter of a closed connection.
+func (g *authGuard) forget(conn net.Conn) {
+	g.mu.Lock()
+	delete(g.conns, conn)
+	g.mu.Unlock()
+}
+
+// exhausted reports whether conn has used up its attempts.
+func (g *authGuard) exhausted(conn net.Conn) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.conns[conn] >= g.maxAttemptsPerConn
+}
+
+// prune drops IPs whose ban and backoff have both expired, at most once a
+// minute. Callers hold mu.
+func (g *authGuard) prune(now time.Time) {
+	if now.Sub(g.lastPrune) < time.Minute {
+		return
+	}
+	g.lastPrune = now
+	for ip, record := range g.ips {
+		if now.After(record.bannedUntil) && now.Sub(record.lastFailure) > max(g.banDuration, g.backoffMax) {
+			delete(g.ips, ip)
+		}
+	}
+}
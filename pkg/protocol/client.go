@@ -0,0 +1,237 @@
+package protocol
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/yashs662/SynchroDB/internal/logger"
+)
+
+// client is the state of one connection, maintained by handleConnection and
+// shown by CLIENT LIST and CLIENT INFO.
+type client struct {
+	id       int64
+	conn     net.Conn
+	created  time.Time
+	bytesIn  atomic.Int64
+	bytesOut atomic.Int64
+	// lastActive is when the last command was received, in Unix nanoseconds
+	lastActive atomic.Int64
+	// closeAfterReply is set when the connection has to be closed once the
+	// reply to the current command is written, as after CLIENT KILL on itself
+	closeAfterReply atomic.Bool
+	// monitoring is set by MONITOR, the connection then only streams commands
+	monitoring atomic.Bool
+	// log adds the address and id of the client to its entries, and requests
+	// counts its commands to give each of them a request ID
+	log      *logger.Logger
+	requests atomic.Int64
+
+	mu            sync.Mutex
+	name          string
+	user          string
+	authenticated bool
+	lastCommand   string
+	// db is the selected database, always 0 as the store has a single one
+	db      int
+	noEvict bool
+}
+
+// registerClient creates the state of a newly accepted connection.
+func (s *Server) registerClient(conn net.Conn) *client {
+	now := time.Now()
+	c := &client{
+		id:      s.nextClientID.Add(1),
+		conn:    conn,
+		created: now,
+	}
+	c.log = logger.With(logger.ClientAddr(conn.RemoteAddr().String()), logger.Int64("client_id", c.id))
+	c.lastActive.Store(now.UnixNano())
+	s.conns.Store(conn, c)
+	return c
+}
+
+// client returns the state of conn, nil for connections not accepted by a
+// listener.
+func (s *Server) client(conn net.Conn) *client {
+	if value, ok := s.conns.Load(conn); ok {
+		return value.(*client)
+	}
+	return nil
+}
+
+// connLogger returns the logger of conn, adding the request ID of the
+// command being run: the client id and the number of the command on the
+// connection.
+func (s *Server) connLogger(conn net.Conn) *logger.Logger {
+	c := s.client(conn)
+	if c == nil {
+		return logger.With(logger.ClientAddr(conn.RemoteAddr().String()))
+	}
+	return c.log.With(logger.RequestID(fmt.Sprintf("%d-%d", c.id, c.requests.Load())))
+}
+
+// clients returns the state of every open connection ordered by id.
+func (s *Server) clients() []*client {
+	var clients []*client
+	s.conns.Range(func(key, value interface{}) bool {
+		clients = append(clients, value.(*client))
+		return true
+	})
+	sort.Slice(clients, func(i, j int) bool { return clients[i].id < clients[j].id })
+	return clients
+}
+
+// received records a command line of n bytes read from the connection.
+func (c *client) received(n int) {
+	c.bytesIn.Add(int64(n))
+	c.lastActive.Store(time.Now().UnixNano())
+}
+
+// write sends data to the connection, counting the bytes written.
+func (c *client) write(data string) error {
+	n, err := c.conn.Write([]byte(data))
+	c.bytesOut.Add(int64(n))
+	netOutputBytes.Add(int64(n))
+	return err
+}
+
+func (c *client) setLastCommand(command string) {
+	c.mu.Lock()
+	c.lastCommand = command
+	c.mu.Unlock()
+}
+
+// getName returns the name set by CLIENT SETNAME, also for a nil client,
+// which has none.
+func (c *client) getName() string {
+	if c == nil {
+		return ""
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.name
+}
+
+// userName returns the ACL user of c, the default user until it
+// authenticates.
+func (c *client) userName() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.authenticated {
+		return defaultUser
+	}
+	return c.user
+}
+
+// kill closes the connection of c. A connection killing itself is closed
+// after it receives the reply.
+func (c *client) kill(current net.Conn) {
+	if c.conn == current {
+		c.closeAfterReply.Store(true)
+		return
+	}
+	c.conn.Close()
+}
+
+// describe formats c for CLIENT LIST and CLIENT INFO.
+func (c *client) describe() string {
+	now := time.Now()
+	idle := now.Sub(time.Unix(0, c.lastActive.Load()))
+
+	user := c.userName()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	flags := ""
+	if c.monitoring.Load() {
+		flags += "O"
+	}
+	if c.noEvict {
+		flags += "e"
+	}
+	if flags == "" {
+		flags = "N"
+	}
+	lastCommand := c.lastCommand
+	if lastCommand == "" {
+		lastCommand = "NULL"
+	}
+	return fmt.Sprintf("id=%d addr=%s laddr=%s name=%s user=%s age=%d idle=%d flags=%s db=%d cmd=%s tot-net-in=%d tot-net-out=%d",
+		c.id, c.conn.RemoteAddr(), c.conn.LocalAddr(), c.name, user,
+		int64(now.Sub(c.created).Seconds()), int64(idle.Seconds()), flags, c.db, lastCommand,
+		c.bytesIn.Load(), c.bytesOut.Load())
+}
+
+// clientPause holds back commands from every client, or only write commands,
+// until a deadline set by CLIENT PAUSE or until CLIENT UNPAUSE.
+type clientPause struct {
+	mu         sync.Mutex
+	until      time.Time
+	writesOnly bool
+	// changed is closed and replaced whenever the pause changes, waking up
+	// the waiting commands
+	changed chan struct{}
+}
+
+func newClientPause() *clientPause {
+	return &clientPause{changed: make(chan struct{})}
+}
+
+// pause holds back commands for d. A pause already in effect is only
+// extended, and pausing all commands takes precedence over pausing writes.
+func (p *clientPause) pause(d time.Duration, writesOnly bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	if now.After(p.until) {
+		p.writesOnly = writesOnly
+	} else {
+		p.writesOnly = p.writesOnly && writesOnly
+	}
+	if until := now.Add(d); until.After(p.until) {
+		p.until = until
+	}
+	p.notify()
+}
+
+func (p *clientPause) unpause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.until = time.Time{}
+	p.notify()
+}
+
+// notify wakes up the waiting commands. Callers hold mu.
+func (p *clientPause) notify() {
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+// wait blocks while a pause applies to a command, write telling whether it
+// writes, or until done is closed.
+func (p *clientPause) wait(write bool, done <-chan struct{}) {
+	for {
+		p.mu.Lock()
+		remaining := time.Until(p.until)
+		applies := remaining > 0 && (write || !p.writesOnly)
+		changed := p.changed
+		p.mu.Unlock()
+		if !applies {
+			return
+		}
+
+		timer := time.NewTimer(remaining)
+		select {
+		case <-timer.C:
+		case <-changed:
+		case <-done:
+			timer.Stop()
+			return
+		}
+		timer.Stop()
+	}
+}
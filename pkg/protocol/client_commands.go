@@ -0,0 +1,216 @@
+package protocol
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yashs662/SynchroDB/internal/utils"
+	"github.com/yashs662/SynchroDB/pkg/database"
+)
+
+type ClientCommand struct {
+	server *Server
+}
+
+func (c *ClientCommand) Execute(conn net.Conn, args []string) string {
+	subcommand := strings.ToUpper(args[0])
+	switch subcommand {
+	case "ID":
+		if client := c.server.client(conn); client != nil {
+			return strconv.FormatInt(client.id, 10)
+		}
+		return "nil"
+	case "INFO":
+		if client := c.server.client(conn); client != nil {
+			return client.describe()
+		}
+		return "nil"
+	case "LIST":
+		return c.list(args[1:])
+	case "KILL":
+		return c.kill(conn, args[1:])
+	case "SETNAME":
+		if len(args) != 2 {
+			return "ERR wrong number of arguments for 'CLIENT SETNAME' command"
+		}
+		if strings.ContainsFunc(args[1], func(r rune) bool { return r <= ' ' || r > '~' }) {
+			return "ERR client names cannot contain spaces, newlines or special characters"
+		}
+		if client := c.server.client(conn); client != nil {
+			client.mu.Lock()
+			client.name = args[1]
+			client.mu.Unlock()
+		}
+		return "OK"
+	case "GETNAME":
+		client := c.server.client(conn)
+		if client == nil {
+			return "nil"
+		}
+		client.mu.Lock()
+		defer client.mu.Unlock()
+		if client.name == "" {
+			return "nil"
+		}
+		return client.name
+	case "PAUSE":
+		if len(args) != 2 && len(args) != 3 {
+			return "ERR wrong number of arguments for 'CLIENT PAUSE' command"
+		}
+		timeout, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || timeout < 0 || timeout > math.MaxInt64/int64(time.Millisecond) {
+			return "ERR timeout is not an integer or out of range"
+		}
+		writesOnly := false
+		if len(args) == 3 {
+			switch strings.ToUpper(args[2]) {
+			case "WRITE":
+				writesOnly = true
+			case "ALL":
+			default:
+				return fmt.Sprintf("ERR %v", errSyntax)
+			}
+		}
+		c.server.pause.pause(time.Duration(timeout)*time.Millisecond, writesOnly)
+		return "OK"
+	case "UNPAUSE":
+		c.server.pause.unpause()
+		return "OK"
+	case "NO-EVICT":
+		if len(args) != 2 {
+			return "ERR wrong number of arguments for 'CLIENT NO-EVICT' command"
+		}
+		var noEvict bool
+		switch strings.ToUpper(args[1]) {
+		case "ON":
+			noEvict = true
+		case "OFF":
+		default:
+			return fmt.Sprintf("ERR %v", errSyntax)
+		}
+		if client := c.server.client(conn); client != nil {
+			client.mu.Lock()
+			client.noEvict = noEvict
+			client.mu.Unlock()
+		}
+		return "OK"
+	}
+	return fmt.Sprintf("ERR unknown subcommand '%s' for 'CLIENT' command", args[0])
+}
+
+// list implements CLIENT LIST [ID <id> ...].
+func (c *ClientCommand) list(args []string) string {
+	var ids map[int64]bool
+	if len(args) > 0 {
+		if !strings.EqualFold(args[0], "ID") || len(args) < 2 {
+			return fmt.Sprintf("ERR %v", errSyntax)
+		}
+		ids = make(map[int64]bool)
+		for _, arg := range args[1:] {
+			id, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil {
+				return "ERR invalid client ID"
+			}
+			ids[id] = true
+		}
+	}
+
+	var lines []string
+	for _, client := range c.server.clients() {
+		if ids == nil || ids[client.id] {
+			lines = append(lines, client.describe())
+		}
+	}
+	return utils.FormatMultilineResponse(strings.Join(lines, "\n"))
+}
+
+// kill implements both CLIENT KILL <addr>, which replies OK or an error, and
+// CLIENT KILL <filter> <value> ..., which replies with the number of killed
+// clients. The filters are ID, ADDR, USER and SKIPME, all of them have to
+// match and SKIPME defaults to yes.
+func (c *ClientCommand) kill(conn net.Conn, args []string) string {
+	switch len(args) {
+	case 0:
+		return "ERR wrong number of arguments for 'CLIENT KILL' command"
+	case 1:
+		for _, client := range c.server.clients() {
+			if client.conn.RemoteAddr().String() == args[0] {
+				client.kill(conn)
+				return "OK"
+			}
+		}
+		return "ERR no such client"
+	}
+	if len(args)%2 != 0 {
+		return fmt.Sprintf("ERR %v", errSyntax)
+	}
+
+	var (
+		id           int64
+		addr, user   string
+		skipMe       = true
+		filterByID   bool
+		filterByUser bool
+	)
+	for i := 0; i < len(args); i += 2 {
+		value := args[i+1]
+		switch strings.ToUpper(args[i]) {
+		case "ID":
+			parsed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return "ERR invalid client ID"
+			}
+			id, filterByID = parsed, true
+		case "ADDR":
+			addr = value
+		case "USER":
+			user, filterByUser = value, true
+		case "SKIPME":
+			switch strings.ToLower(value) {
+			case "yes":
+				skipMe = true
+			case "no":
+				skipMe = false
+			default:
+				return fmt.Sprintf("ERR %v", errSyntax)
+			}
+		default:
+			return fmt.Sprintf("ERR %v", errSyntax)
+		}
+	}
+
+	killed := 0
+	for _, client := range c.server.clients() {
+		if filterByID && client.id != id ||
+			addr != "" && client.conn.RemoteAddr().String() != addr ||
+			skipMe && client.conn == conn {
+			continue
+		}
+		if filterByUser && client.userName() != user {
+			continue
+		}
+		client.kill(conn)
+		killed++
+	}
+	return strconv.Itoa(killed)
+}
+
+func (c *ClientCommand) Replay(args []string, store *database.KVStore) error {
+	return nil // Connection management is not persisted in the AOF
+}
+
+func (c *ClientCommand) GetCommandInfo() CommandDescription {
+	return CommandDescription{
+		Command:       "CLIENT",
+		Name:          "Client",
+		Syntax:        "CLIENT ID | CLIENT INFO | CLIENT LIST [ID <id> ...] | CLIENT KILL <addr> | CLIENT KILL [ID <id>] [ADDR <addr>] [USER <username>] [SKIPME yes|no] | CLIENT SETNAME <name> | CLIENT GETNAME | CLIENT PAUSE <timeout-ms> [WRITE|ALL] | CLIENT UNPAUSE | CLIENT NO-EVICT ON|OFF",
+		HelpText:      "Inspect, name, kill and pause client connections",
+		Arity:         -2,
+		Flags:         []CommandFlag{FlagAdmin, FlagNoScript},
+		ACLCategories: []string{"@connection"},
+	}
+}
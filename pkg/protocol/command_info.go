@@ -0,0 +1,109 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/yashs662/SynchroDB/internal/utils"
+	"github.com/yashs662/SynchroDB/pkg/database"
+)
+
+// commandInfoLine formats a command's metadata as a single line of the form
+// "name arity [flags] first last step [categories]".
+func commandInfoLine(info CommandDescription) string {
+	flags := make([]string, len(info.Flags))
+	for i, flag := range info.Flags {
+		flags[i] = string(flag)
+	}
+	return fmt.Sprintf("%s %d [%s] %d %d %d [%s]",
+		strings.ToLower(info.Command), info.Arity, strings.Join(flags, " "),
+		info.FirstKey, info.LastKey, info.KeyStep, strings.Join(info.Categories(), " "))
+}
+
+type CommandCommand struct {
+	server *Server
+}
+
+func (c *CommandCommand) Execute(conn net.Conn, args []string) string {
+	if len(args) == 0 {
+		lines := make([]string, 0)
+		for _, command := range c.server.commands() {
+			lines = append(lines, commandInfoLine(command.GetCommandInfo()))
+		}
+		return utils.FormatMultilineResponse(strings.Join(lines, "\n"))
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "COUNT":
+		if len(args) != 1 {
+			return "ERR wrong number of arguments for 'COMMAND COUNT' command"
+		}
+		return strconv.Itoa(len(c.server.commands()))
+	case "INFO":
+		if len(args) < 2 {
+			return "ERR wrong number of arguments for 'COMMAND INFO' command"
+		}
+		lines := make([]string, len(args)-1)
+		for i, name := range args[1:] {
+			lines[i] = "nil"
+			if _, info, exists := c.server.lookupCommand(strings.ToUpper(name)); exists {
+				lines[i] = commandInfoLine(info)
+			}
+		}
+		return utils.FormatMultilineResponse(strings.Join(lines, "\n"))
+	case "DOCS":
+		descriptions := make([]CommandDescription, 0)
+		if len(args) == 1 {
+			for _, command := range c.server.commands() {
+				descriptions = append(descriptions, command.GetCommandInfo())
+			}
+		}
+		for _, name := range args[1:] {
+			if _, info, exists := c.server.lookupCommand(strings.ToUpper(name)); exists {
+				descriptions = append(descriptions, info)
+			}
+		}
+		stringifiedJSON, err := json.Marshal(descriptions)
+		if err != nil {
+			return fmt.Sprintf("ERR failed to describe commands: %v", err)
+		}
+		return string(stringifiedJSON)
+	case "GETKEYS":
+		if len(args) < 2 {
+			return "ERR wrong number of arguments for 'COMMAND GETKEYS' command"
+		}
+		_, info, exists := c.server.lookupCommand(strings.ToUpper(args[1]))
+		switch {
+		case !exists:
+			return "ERR invalid command specified"
+		case !info.CheckArity(len(args) - 2):
+			return "ERR invalid number of arguments specified for command"
+		case info.HasFlag(FlagMovableKeys):
+			return "ERR the keys of this command cannot be determined from its arguments"
+		}
+		keys := info.Keys(args[2:])
+		if len(keys) == 0 {
+			return "ERR the command has no key arguments"
+		}
+		return utils.FormatMultilineResponse(strings.Join(keys, "\n"))
+	}
+	return fmt.Sprintf("ERR unknown subcommand '%s' for 'COMMAND' command", args[0])
+}
+
+func (c *CommandCommand) Replay(args []string, store *database.KVStore) error {
+	return nil // No-op for replay
+}
+
+func (c *CommandCommand) GetCommandInfo() CommandDescription {
+	return CommandDescription{
+		Command:       "COMMAND",
+		Name:          "Command Introspection",
+		Syntax:        "COMMAND [COUNT | INFO <command> [<command> ...] | DOCS [<command> ...] | GETKEYS <command> [<arg> ...]]",
+		HelpText:      "Describe the commands supported by the server: arity, flags, key positions and ACL categories",
+		Arity:         -1,
+		ACLCategories: []string{"@connection"},
+	}
+}
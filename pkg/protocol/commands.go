@@ -3,11 +3,13 @@ package protocol
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"net"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/yashs662/SynchroDB/internal/logger"
 	"github.com/yashs662/SynchroDB/internal/utils"
 	"github.com/yashs662/SynchroDB/pkg/database"
 )
@@ -25,6 +27,44 @@ func AllCommands(server *Server) []Command {
 		&KeysCommand{server: server},
 		&IncrCommand{server: server},
 		&DecrCommand{server: server},
+		&IncrByCommand{server: server},
+		&DecrByCommand{server: server},
+		&IncrByFloatCommand{server: server},
+		&MSetCommand{server: server},
+		&MGetCommand{server: server},
+		&SetNXCommand{server: server},
+		&GetSetCommand{server: server},
+		&AppendCommand{server: server},
+		&StrLenCommand{server: server},
+		&GetRangeCommand{server: server},
+		&SetRangeCommand{server: server},
+		&GetDelCommand{server: server},
+		&GetExCommand{server: server},
+		&ExistsCommand{server: server},
+		&TypeCommand{server: server},
+		&RenameCommand{server: server},
+		&CopyCommand{server: server},
+		&PersistCommand{server: server},
+		&PExpireCommand{server: server},
+		&ExpireAtCommand{server: server},
+		&PExpireAtCommand{server: server},
+		&PTTLCommand{server: server},
+		&RandomKeyCommand{server: server},
+		&UnlinkCommand{server: server},
+		&TouchCommand{server: server},
+		&EvalCommand{server: server},
+		&EvalSHACommand{server: server},
+		&ScriptCommand{server: server},
+		&ACLCommand{server: server},
+		&ClientCommand{server: server},
+		&ConfigCommand{server: server},
+		&InfoCommand{server: server},
+		&LatencyCommand{server: server},
+		&MonitorCommand{server: server},
+		&PublishCommand{server: server},
+		&SubscribeCommand{server: server},
+		&SlowlogCommand{server: server},
+		&CommandCommand{server: server},
 		&HelpCommand{server: server},
 	}
 }
@@ -34,6 +74,111 @@ type CommandDescription struct {
 	Name     string
 	Syntax   string
 	HelpText string
+	// Arity is the number of arguments including the command name. A negative
+	// arity means at least -Arity arguments and zero disables the check.
+	Arity int           `json:",omitempty"`
+	Flags []CommandFlag `json:",omitempty"`
+	// FirstKey, LastKey and KeyStep locate the key arguments, counting the
+	// command name as position 0. A negative LastKey counts from the end, so
+	// -1 is the last argument. FirstKey 0 means the command takes no keys.
+	FirstKey int `json:",omitempty"`
+	LastKey  int `json:",omitempty"`
+	KeyStep  int `json:",omitempty"`
+	// ACLCategories lists the categories of the command that do not follow
+	// from its flags, such as @string or @keyspace. See Categories.
+	ACLCategories []string `json:",omitempty"`
+}
+
+// CommandFlag describes a property of a command.
+type CommandFlag string
+
+const (
+	// FlagWrite marks commands that modify the data set.
+	FlagWrite CommandFlag = "write"
+	// FlagReadOnly marks commands that only read the data set.
+	FlagReadOnly CommandFlag = "readonly"
+	// FlagAdmin marks administrative commands.
+	FlagAdmin CommandFlag = "admin"
+	// FlagBlocking marks commands that may block the connection.
+	FlagBlocking CommandFlag = "blocking"
+	// FlagNoScript marks commands that cannot be called from scripts.
+	FlagNoScript CommandFlag = "noscript"
+	// FlagNoAuth marks commands that can run before the client authenticates.
+	FlagNoAuth CommandFlag = "no_auth"
+	// FlagFast marks commands that run in constant or log time.
+	FlagFast CommandFlag = "fast"
+	// FlagMovableKeys marks commands whose keys cannot be found from the key
+	// positions alone, such as EVAL.
+	FlagMovableKeys CommandFlag = "movablekeys"
+)
+
+// HasFlag reports whether the command has the given flag.
+func (d CommandDescription) HasFlag(flag CommandFlag) bool {
+	for _, f := range d.Flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// Categories returns all the ACL categories of the command: the explicit
+// ACLCategories plus the ones implied by its flags.
+func (d CommandDescription) Categories() []string {
+	categories := append([]string{}, d.ACLCategories...)
+	if d.HasFlag(FlagWrite) {
+		categories = append(categories, "@write")
+	}
+	if d.HasFlag(FlagReadOnly) {
+		categories = append(categories, "@read")
+	}
+	if d.HasFlag(FlagAdmin) {
+		categories = append(categories, "@admin", "@dangerous")
+	}
+	if d.HasFlag(FlagBlocking) {
+		categories = append(categories, "@blocking")
+	}
+	if d.HasFlag(FlagFast) {
+		categories = append(categories, "@fast")
+	} else {
+		categories = append(categories, "@slow")
+	}
+	return categories
+}
+
+// CheckArity reports whether a call with the given number of arguments,
+// excluding the command name, satisfies the command's arity.
+func (d CommandDescription) CheckArity(argc int) bool {
+	argc++ // arity counts the command name
+	switch {
+	case d.Arity > 0:
+		return argc == d.Arity
+	case d.Arity < 0:
+		return argc >= -d.Arity
+	}
+	return true
+}
+
+// Keys returns the key arguments of a call, given its arguments excluding the
+// command name.
+func (d CommandDescription) Keys(args []string) []string {
+	if d.FirstKey <= 0 {
+		return nil
+	}
+	last := d.LastKey
+	if last < 0 {
+		last += len(args) + 1
+	}
+	step := d.KeyStep
+	if step <= 0 {
+		step = 1
+	}
+
+	var keys []string
+	for i := d.FirstKey; i <= last && i <= len(args); i += step {
+		keys = append(keys, args[i-1])
+	}
+	return keys
 }
 
 type Command interface {
@@ -47,15 +192,30 @@ type AuthCommand struct {
 }
 
 func (c *AuthCommand) Execute(conn net.Conn, args []string) string {
-	if len(args) != 1 {
-		return "ERR missing password"
+	if len(args) > 2 {
+		return fmt.Sprintf("ERR %v", errSyntax)
 	}
-
-	if args[0] == c.server.dbPassword {
-		c.server.authenticateClient(conn)
-		return "OK"
+	user, password := defaultUser, args[0]
+	if len(args) == 2 {
+		user, password = args[0], args[1]
+	}
+	guard := c.server.authGuard
+	if wait := guard.retryAfter(conn); wait > 0 {
+		if guard.reject(conn) {
+			return "ERR too many failed authentication attempts, closing the connection"
+		}
+		return fmt.Sprintf("ERR too many failed authentication attempts, retry in %s", wait.Round(time.Millisecond))
+	}
+	if !c.server.acl.authenticate(user, password) {
+		c.server.connLogger(conn).Warn("Failed authentication", logger.User(user))
+		if guard.fail(conn) {
+			return "ERR too many failed authentication attempts, closing the connection"
+		}
+		return "ERR invalid username-password pair or user is disabled"
 	}
-	return "ERR invalid password"
+	guard.succeed(conn)
+	c.server.authenticateClient(conn, user)
+	return "OK"
 }
 
 func (c *AuthCommand) Replay(args []string, store *database.KVStore) error {
@@ -64,10 +224,13 @@ func (c *AuthCommand) Replay(args []string, store *database.KVStore) error {
 
 func (c *AuthCommand) GetCommandInfo() CommandDescription {
 	return CommandDescription{
-		Command:  "AUTH",
-		Name:     "Authenticate",
-		Syntax:   "AUTH <password>",
-		HelpText: "Authenticate with the server",
+		Command:       "AUTH",
+		Name:          "Authenticate",
+		Syntax:        "AUTH [<username>] <password>",
+		HelpText:      "Authenticate with the server, as the default user when no username is given",
+		Arity:         -2,
+		Flags:         []CommandFlag{FlagNoAuth, FlagNoScript, FlagFast},
+		ACLCategories: []string{"@connection"},
 	}
 }
 
@@ -86,10 +249,13 @@ func (c *PingCommand) Replay(args []string, store *database.KVStore) error {
 
 func (c *PingCommand) GetCommandInfo() CommandDescription {
 	return CommandDescription{
-		Command:  "PING",
-		Name:     "Ping",
-		Syntax:   "PING",
-		HelpText: "Check if the server is alive",
+		Command:       "PING",
+		Name:          "Ping",
+		Syntax:        "PING",
+		HelpText:      "Check if the server is alive",
+		Arity:         -1,
+		Flags:         []CommandFlag{FlagFast},
+		ACLCategories: []string{"@connection"},
 	}
 }
 
@@ -98,53 +264,53 @@ type SetCommand struct {
 }
 
 func (c *SetCommand) Execute(conn net.Conn, args []string) string {
-	if len(args) < 2 {
-		return "ERR wrong number of arguments for 'SET' command"
-	}
 	key, value := args[0], args[1]
-	if len(args) == 4 && args[2] == "EX" {
-		ttl, err := strconv.Atoi(args[3])
-		if err != nil || ttl <= 0 {
-			return "ERR invalid TTL"
-		}
-		c.server.store.SetWithTTL(key, value, time.Duration(ttl)*time.Second)
-		if c.server.persistenceEnabled && !strings.HasPrefix(key, "synchrodb-benchmark:") {
-			c.server.aofWriter.Write(fmt.Sprintf("SET %s %s EX %d", key, value, ttl))
-		}
-		return "OK"
-	} else if len(args) == 2 {
-		c.server.store.Set(key, value)
-		if c.server.persistenceEnabled && !strings.HasPrefix(key, "synchrodb-benchmark:") {
-			c.server.aofWriter.Write(fmt.Sprintf("SET %s %s", key, value))
+	opts, get, err := parseSetOptions(args[2:])
+	if err != nil {
+		return fmt.Sprintf("ERR %v", err)
+	}
+
+	old, existed, applied := c.server.store.SetWithOptions(key, value, opts)
+	if applied {
+		c.server.appendToAOF(setAOFArgs(key, value, opts)...)
+	}
+	if get {
+		if !existed {
+			return "nil"
 		}
-		return "OK"
+		return old
 	}
-	return "ERR invalid arguments for 'SET' command"
+	if !applied {
+		return "nil"
+	}
+	return "OK"
 }
 
 func (c *SetCommand) Replay(args []string, store *database.KVStore) error {
-	if len(args) == 2 {
-		key, value := args[0], args[1]
-		store.Set(key, value)
-	} else if len(args) == 4 && args[2] == "EX" {
-		key, value := args[0], args[1]
-		ttl, err := strconv.Atoi(args[3])
-		if err != nil {
-			return fmt.Errorf("invalid TTL value: %v", err)
-		}
-		store.SetWithTTL(key, value, time.Duration(ttl)*time.Second)
-	} else {
+	if len(args) < 2 {
 		return fmt.Errorf("invalid arguments for 'SET' command")
 	}
+	key, value := args[0], args[1]
+	opts, _, err := parseSetOptions(args[2:])
+	if err != nil {
+		return err
+	}
+	store.SetWithOptions(key, value, opts)
 	return nil
 }
 
 func (c *SetCommand) GetCommandInfo() CommandDescription {
 	return CommandDescription{
-		Command:  "SET",
-		Name:     "Set",
-		Syntax:   "SET <key> <value> [EX <seconds>]",
-		HelpText: "Set a key with a value and an optional expiration",
+		Command:       "SET",
+		Name:          "Set",
+		Syntax:        "SET <key> <value> [NX | XX] [GET] [EX <seconds> | PX <milliseconds> | EXAT <unix-seconds> | PXAT <unix-milliseconds> | KEEPTTL]",
+		HelpText:      "Set a key with a value, optionally only if it does (not) exist, returning the old value or with an expiration",
+		Arity:         -3,
+		Flags:         []CommandFlag{FlagWrite},
+		FirstKey:      1,
+		LastKey:       1,
+		KeyStep:       1,
+		ACLCategories: []string{"@string"},
 	}
 }
 
@@ -153,9 +319,6 @@ type GetCommand struct {
 }
 
 func (c *GetCommand) Execute(conn net.Conn, args []string) string {
-	if len(args) != 1 {
-		return "ERR wrong number of arguments for 'GET' command"
-	}
 	key := args[0]
 	value, exists := c.server.store.Get(key)
 	if !exists {
@@ -170,10 +333,16 @@ func (c *GetCommand) Replay(args []string, store *database.KVStore) error {
 
 func (c *GetCommand) GetCommandInfo() CommandDescription {
 	return CommandDescription{
-		Command:  "GET",
-		Name:     "Get",
-		Syntax:   "GET <key>",
-		HelpText: "Get the value of a key",
+		Command:       "GET",
+		Name:          "Get",
+		Syntax:        "GET <key>",
+		HelpText:      "Get the value of a key",
+		Arity:         2,
+		Flags:         []CommandFlag{FlagReadOnly, FlagFast},
+		FirstKey:      1,
+		LastKey:       1,
+		KeyStep:       1,
+		ACLCategories: []string{"@string"},
 	}
 }
 
@@ -182,34 +351,29 @@ type DelCommand struct {
 }
 
 func (c *DelCommand) Execute(conn net.Conn, args []string) string {
-	if len(args) != 1 {
-		return "ERR wrong number of arguments for 'DEL' command"
-	}
-	key := args[0]
-	if c.server.store.Del(key) {
-		if c.server.persistenceEnabled && !strings.HasPrefix(key, "synchrodb-benchmark:") {
-			c.server.aofWriter.Write(fmt.Sprintf("DEL %s", key))
-		}
-		return "OK"
-	}
-	return "nil"
+	return deleteKeys(c.server, args)
 }
 
 func (c *DelCommand) Replay(args []string, store *database.KVStore) error {
-	if len(args) != 1 {
+	if len(args) < 1 {
 		return fmt.Errorf("invalid arguments for 'DEL' command")
 	}
-	key := args[0]
-	store.Del(key)
+	store.DelKeys(args)
 	return nil
 }
 
 func (c *DelCommand) GetCommandInfo() CommandDescription {
 	return CommandDescription{
-		Command:  "DEL",
-		Name:     "Delete",
-		Syntax:   "DEL <key>",
-		HelpText: "Delete a key",
+		Command:       "DEL",
+		Name:          "Delete",
+		Syntax:        "DEL <key> [<key> ...]",
+		HelpText:      "Delete one or more keys and return how many were removed",
+		Arity:         -2,
+		Flags:         []CommandFlag{FlagWrite},
+		FirstKey:      1,
+		LastKey:       -1,
+		KeyStep:       1,
+		ACLCategories: []string{"@keyspace"},
 	}
 }
 
@@ -218,23 +382,15 @@ type ExpireCommand struct {
 }
 
 func (c *ExpireCommand) Execute(conn net.Conn, args []string) string {
-	if len(args) != 2 {
-		return "ERR wrong number of arguments for 'EXPIRE' command"
-	}
-	key := args[0]
-	ttl, err := strconv.Atoi(args[1])
-	if err != nil || ttl <= 0 {
-		return "ERR invalid TTL"
-	}
-	if c.server.store.SetExpire(key, ttl) {
-		if c.server.persistenceEnabled && !strings.HasPrefix(key, "synchrodb-benchmark:") {
-			c.server.aofWriter.Write(fmt.Sprintf("EXPIRE %s %d", key, ttl))
-		}
-		return "OK"
+	expireAt, err := parseExpiration("EX", args[1])
+	if err != nil {
+		return fmt.Sprintf("ERR %v", err)
 	}
-	return "ERR key does not exist"
+	return expireKey(c.server, args[0], expireAt)
 }
 
+// Replay handles EXPIRE entries written by older versions, which persisted the
+// relative TTL. Newer versions persist expirations as PEXPIREAT.
 func (c *ExpireCommand) Replay(args []string, store *database.KVStore) error {
 	if len(args) != 2 {
 		return fmt.Errorf("invalid arguments for 'EXPIRE' command")
@@ -250,10 +406,16 @@ func (c *ExpireCommand) Replay(args []string, store *database.KVStore) error {
 
 func (c *ExpireCommand) GetCommandInfo() CommandDescription {
 	return CommandDescription{
-		Command:  "EXPIRE",
-		Name:     "Expire",
-		Syntax:   "EXPIRE <key> <seconds>",
-		HelpText: "Set a key's time to live in seconds",
+		Command:       "EXPIRE",
+		Name:          "Expire",
+		Syntax:        "EXPIRE <key> <seconds>",
+		HelpText:      "Set a key's time to live in seconds",
+		Arity:         3,
+		Flags:         []CommandFlag{FlagWrite, FlagFast},
+		FirstKey:      1,
+		LastKey:       1,
+		KeyStep:       1,
+		ACLCategories: []string{"@keyspace"},
 	}
 }
 
@@ -262,18 +424,7 @@ type TTLCommand struct {
 }
 
 func (c *TTLCommand) Execute(conn net.Conn, args []string) string {
-	if len(args) != 1 {
-		return "ERR wrong number of arguments for 'TTL' command"
-	}
-	key := args[0]
-	ttl := c.server.store.TTL(key)
-	if ttl == -2 {
-		return "-2"
-	} else if ttl == -1 {
-		return "-1"
-	} else {
-		return fmt.Sprintf("%ds", ttl)
-	}
+	return strconv.Itoa(c.server.store.TTL(args[0]))
 }
 
 func (c *TTLCommand) Replay(args []string, store *database.KVStore) error {
@@ -282,10 +433,16 @@ func (c *TTLCommand) Replay(args []string, store *database.KVStore) error {
 
 func (c *TTLCommand) GetCommandInfo() CommandDescription {
 	return CommandDescription{
-		Command:  "TTL",
-		Name:     "Time to Live",
-		Syntax:   "TTL <key>",
-		HelpText: "Get the time to live of a key",
+		Command:       "TTL",
+		Name:          "Time to Live",
+		Syntax:        "TTL <key>",
+		HelpText:      "Get the time to live of a key in seconds, -1 if it has no expiration and -2 if it does not exist",
+		Arity:         2,
+		Flags:         []CommandFlag{FlagReadOnly, FlagFast},
+		FirstKey:      1,
+		LastKey:       1,
+		KeyStep:       1,
+		ACLCategories: []string{"@keyspace"},
 	}
 }
 
@@ -295,9 +452,7 @@ type FlushDBCommand struct {
 
 func (c *FlushDBCommand) Execute(conn net.Conn, args []string) string {
 	c.server.store.FlushDB()
-	if c.server.persistenceEnabled {
-		c.server.aofWriter.Write("FLUSHDB")
-	}
+	c.server.appendToAOF("FLUSHDB")
 	return "OK"
 }
 
@@ -308,10 +463,13 @@ func (c *FlushDBCommand) Replay(args []string, store *database.KVStore) error {
 
 func (c *FlushDBCommand) GetCommandInfo() CommandDescription {
 	return CommandDescription{
-		Command:  "FLUSHDB",
-		Name:     "Flush Database",
-		Syntax:   "FLUSHDB",
-		HelpText: "Remove all keys from the database",
+		Command:       "FLUSHDB",
+		Name:          "Flush Database",
+		Syntax:        "FLUSHDB",
+		HelpText:      "Remove all keys from the database",
+		Arity:         1,
+		Flags:         []CommandFlag{FlagWrite},
+		ACLCategories: []string{"@keyspace", "@dangerous"},
 	}
 }
 
@@ -320,9 +478,6 @@ type KeysCommand struct {
 }
 
 func (c *KeysCommand) Execute(conn net.Conn, args []string) string {
-	if len(args) < 1 {
-		return "ERR missing pattern"
-	}
 	pattern := args[0]
 	keys := c.server.store.Keys(pattern)
 	if len(keys) > 20 {
@@ -341,10 +496,13 @@ func (c *KeysCommand) Replay(args []string, store *database.KVStore) error {
 
 func (c *KeysCommand) GetCommandInfo() CommandDescription {
 	return CommandDescription{
-		Command:  "KEYS",
-		Name:     "Keys",
-		Syntax:   "KEYS <pattern>",
-		HelpText: "Find all keys matching the given pattern",
+		Command:       "KEYS",
+		Name:          "Keys",
+		Syntax:        "KEYS <pattern>",
+		HelpText:      "Find all keys matching the given pattern",
+		Arity:         2,
+		Flags:         []CommandFlag{FlagReadOnly},
+		ACLCategories: []string{"@keyspace", "@dangerous"},
 	}
 }
 
@@ -353,18 +511,13 @@ type IncrCommand struct {
 }
 
 func (c *IncrCommand) Execute(conn net.Conn, args []string) string {
-	if len(args) < 1 {
-		return "ERR missing key"
-	}
 	key := args[0]
 	value, err := c.server.store.Incr(key)
 	if err != nil {
 		return fmt.Sprintf("ERR %v", err)
 	}
-	if c.server.persistenceEnabled && !strings.HasPrefix(key, "synchrodb-benchmark:") {
-		c.server.aofWriter.Write(fmt.Sprintf("INCR %s", key))
-	}
-	return strconv.Itoa(value)
+	c.server.appendToAOF("INCR", key)
+	return strconv.FormatInt(value, 10)
 }
 
 func (c *IncrCommand) Replay(args []string, store *database.KVStore) error {
@@ -378,10 +531,16 @@ func (c *IncrCommand) Replay(args []string, store *database.KVStore) error {
 
 func (c *IncrCommand) GetCommandInfo() CommandDescription {
 	return CommandDescription{
-		Command:  "INCR",
-		Name:     "Increment",
-		Syntax:   "INCR <key>",
-		HelpText: "Increment the integer value of a key by one",
+		Command:       "INCR",
+		Name:          "Increment",
+		Syntax:        "INCR <key>",
+		HelpText:      "Increment the integer value of a key by one",
+		Arity:         2,
+		Flags:         []CommandFlag{FlagWrite, FlagFast},
+		FirstKey:      1,
+		LastKey:       1,
+		KeyStep:       1,
+		ACLCategories: []string{"@string"},
 	}
 }
 
@@ -390,18 +549,13 @@ type DecrCommand struct {
 }
 
 func (c *DecrCommand) Execute(conn net.Conn, args []string) string {
-	if len(args) < 1 {
-		return "ERR missing key"
-	}
 	key := args[0]
 	value, err := c.server.store.Decr(key)
 	if err != nil {
 		return fmt.Sprintf("ERR %v", err)
 	}
-	if c.server.persistenceEnabled && !strings.HasPrefix(key, "synchrodb-benchmark:") {
-		c.server.aofWriter.Write(fmt.Sprintf("DECR %s", key))
-	}
-	return strconv.Itoa(value)
+	c.server.appendToAOF("DECR", key)
+	return strconv.FormatInt(value, 10)
 }
 
 func (c *DecrCommand) Replay(args []string, store *database.KVStore) error {
@@ -415,10 +569,156 @@ func (c *DecrCommand) Replay(args []string, store *database.KVStore) error {
 
 func (c *DecrCommand) GetCommandInfo() CommandDescription {
 	return CommandDescription{
-		Command:  "DECR",
-		Name:     "Decrement",
-		Syntax:   "DECR <key>",
-		HelpText: "Decrement the integer value of a key by one",
+		Command:       "DECR",
+		Name:          "Decrement",
+		Syntax:        "DECR <key>",
+		HelpText:      "Decrement the integer value of a key by one",
+		Arity:         2,
+		Flags:         []CommandFlag{FlagWrite, FlagFast},
+		FirstKey:      1,
+		LastKey:       1,
+		KeyStep:       1,
+		ACLCategories: []string{"@string"},
+	}
+}
+
+type IncrByCommand struct {
+	server *Server
+}
+
+func (c *IncrByCommand) Execute(conn net.Conn, args []string) string {
+	key := args[0]
+	delta, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return fmt.Sprintf("ERR %v", database.ErrNotInteger)
+	}
+	value, err := c.server.store.IncrBy(key, delta)
+	if err != nil {
+		return fmt.Sprintf("ERR %v", err)
+	}
+	c.server.appendToAOF("INCRBY", key, args[1])
+	return strconv.FormatInt(value, 10)
+}
+
+func (c *IncrByCommand) Replay(args []string, store *database.KVStore) error {
+	if len(args) != 2 {
+		return fmt.Errorf("invalid arguments for 'INCRBY' command")
+	}
+	delta, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid increment value: %v", err)
+	}
+	_, err = store.IncrBy(args[0], delta)
+	return err
+}
+
+func (c *IncrByCommand) GetCommandInfo() CommandDescription {
+	return CommandDescription{
+		Command:       "INCRBY",
+		Name:          "Increment By",
+		Syntax:        "INCRBY <key> <increment>",
+		HelpText:      "Increment the integer value of a key by the given amount",
+		Arity:         3,
+		Flags:         []CommandFlag{FlagWrite, FlagFast},
+		FirstKey:      1,
+		LastKey:       1,
+		KeyStep:       1,
+		ACLCategories: []string{"@string"},
+	}
+}
+
+type DecrByCommand struct {
+	server *Server
+}
+
+func (c *DecrByCommand) Execute(conn net.Conn, args []string) string {
+	key := args[0]
+	delta, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return fmt.Sprintf("ERR %v", database.ErrNotInteger)
+	}
+	if delta == math.MinInt64 {
+		return fmt.Sprintf("ERR %v", database.ErrOverflow)
+	}
+	value, err := c.server.store.IncrBy(key, -delta)
+	if err != nil {
+		return fmt.Sprintf("ERR %v", err)
+	}
+	c.server.appendToAOF("DECRBY", key, args[1])
+	return strconv.FormatInt(value, 10)
+}
+
+func (c *DecrByCommand) Replay(args []string, store *database.KVStore) error {
+	if len(args) != 2 {
+		return fmt.Errorf("invalid arguments for 'DECRBY' command")
+	}
+	delta, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || delta == math.MinInt64 {
+		return fmt.Errorf("invalid decrement value: %s", args[1])
+	}
+	_, err = store.IncrBy(args[0], -delta)
+	return err
+}
+
+func (c *DecrByCommand) GetCommandInfo() CommandDescription {
+	return CommandDescription{
+		Command:       "DECRBY",
+		Name:          "Decrement By",
+		Syntax:        "DECRBY <key> <decrement>",
+		HelpText:      "Decrement the integer value of a key by the given amount",
+		Arity:         3,
+		Flags:         []CommandFlag{FlagWrite, FlagFast},
+		FirstKey:      1,
+		LastKey:       1,
+		KeyStep:       1,
+		ACLCategories: []string{"@string"},
+	}
+}
+
+type IncrByFloatCommand struct {
+	server *Server
+}
+
+func (c *IncrByFloatCommand) Execute(conn net.Conn, args []string) string {
+	key := args[0]
+	delta, err := strconv.ParseFloat(args[1], 64)
+	if err != nil || math.IsNaN(delta) || math.IsInf(delta, 0) {
+		return fmt.Sprintf("ERR %v", database.ErrNotFloat)
+	}
+	value, err := c.server.store.IncrByFloat(key, delta)
+	if err != nil {
+		return fmt.Sprintf("ERR %v", err)
+	}
+	// Persist the result rather than the increment so replaying the AOF does
+	// not depend on floating point rounding of the intermediate steps.
+	c.server.appendToAOF("SET", key, value, "KEEPTTL")
+	return value
+}
+
+func (c *IncrByFloatCommand) Replay(args []string, store *database.KVStore) error {
+	if len(args) != 2 {
+		return fmt.Errorf("invalid arguments for 'INCRBYFLOAT' command")
+	}
+	delta, err := strconv.ParseFloat(args[1], 64)
+	if err != nil {
+		return fmt.Errorf("invalid increment value: %v", err)
+	}
+	_, err = store.IncrByFloat(args[0], delta)
+	return err
+}
+
+func (c *IncrByFloatCommand) GetCommandInfo() CommandDescription {
+	return CommandDescription{
+		Command:       "INCRBYFLOAT",
+		Name:          "Increment By Float",
+		Syntax:        "INCRBYFLOAT <key> <increment>",
+		HelpText:      "Increment the floating point value of a key by the given amount",
+		Arity:         3,
+		Flags:         []CommandFlag{FlagWrite, FlagFast},
+		FirstKey:      1,
+		LastKey:       1,
+		KeyStep:       1,
+		ACLCategories: []string{"@string"},
 	}
 }
 
@@ -429,7 +729,7 @@ type HelpCommand struct {
 func (c *HelpCommand) Execute(conn net.Conn, args []string) string {
 	// create json stringified response of all command descriptions
 	var commandDescriptions []CommandDescription
-	for _, command := range AllCommands(c.server) {
+	for _, command := range c.server.commands() {
 		commandDescriptions = append(commandDescriptions, command.GetCommandInfo())
 	}
 
@@ -446,9 +746,11 @@ func (c *HelpCommand) Replay(args []string, store *database.KVStore) error {
 
 func (c *HelpCommand) GetCommandInfo() CommandDescription {
 	return CommandDescription{
-		Command:  "HELP",
-		Name:     "Help",
-		Syntax:   "HELP",
-		HelpText: "Show this help message",
+		Command:       "HELP",
+		Name:          "Help",
+		Syntax:        "HELP",
+		HelpText:      "Show this help message",
+		Arity:         -1,
+		ACLCategories: []string{"@connection"},
 	}
 }
@@ -0,0 +1,422 @@
+package protocol
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yashs662/SynchroDB/internal/auth"
+	"github.com/yashs662/SynchroDB/internal/config"
+	"github.com/yashs662/SynchroDB/internal/latency"
+	"github.com/yashs662/SynchroDB/internal/logger"
+	"github.com/yashs662/SynchroDB/internal/ratelimit"
+	"github.com/yashs662/SynchroDB/internal/utils"
+	"github.com/yashs662/SynchroDB/pkg/database"
+)
+
+// configParam is a setting that CONFIG GET reads and CONFIG SET changes at
+// runtime. parse validates a new value and returns the function applying it,
+// so CONFIG SET can check every value before changing anything.
+type configParam struct {
+	name string
+	// path is the key of the setting in the config file, for CONFIG REWRITE
+	path  string
+	get   func(s *Server) string
+	parse func(s *Server, value string) (func(), error)
+	// load returns the value of the setting in cfg, with the default applied,
+	// for ReloadConfig
+	load func(cfg *config.Config) string
+	// persist returns the value written by CONFIG REWRITE, get by default
+	persist func(s *Server) (string, error)
+	// overrides are keys CONFIG REWRITE removes, as the setting would not
+	// take effect next to them
+	overrides []string
+}
+
+// noPassword is the value CONFIG SET password takes to remove the password,
+// so that an empty value cannot remove it by mistake.
+const noPassword = "nopass"
+
+var configParams = []configParam{
+	{
+		name: "rate_limit",
+		path: "server.rate_limit",
+		get:  func(s *Server) string { return formatRate(s.rateLimits.commands.Limit()) },
+		parse: func(s *Server, value string) (func(), error) {
+			return parseRate(s.rateLimits.commands, value, false)
+		},
+		load: func(cfg *config.Config) string { return strconv.Itoa(cfg.Server.RateLimit) },
+	},
+	{
+		name: "rate_limit_burst",
+		path: "server.rate_limit_burst",
+		get:  func(s *Server) string { return strconv.Itoa(s.rateLimits.commands.Limit().Burst) },
+		parse: func(s *Server, value string) (func(), error) {
+			return parseRate(s.rateLimits.commands, value, true)
+		},
+		load: func(cfg *config.Config) string { return strconv.Itoa(cfg.Server.RateLimitBurst) },
+	},
+	{
+		name: "write_rate_limit",
+		path: "server.write_rate_limit",
+		get:  func(s *Server) string { return formatRate(s.rateLimits.writes.Limit()) },
+		parse: func(s *Server, value string) (func(), error) {
+			return parseRate(s.rateLimits.writes, value, false)
+		},
+		load: func(cfg *config.Config) string { return strconv.Itoa(cfg.Server.WriteRateLimit) },
+	},
+	{
+		name: "write_rate_limit_burst",
+		path: "server.write_rate_limit_burst",
+		get:  func(s *Server) string { return strconv.Itoa(s.rateLimits.writes.Limit().Burst) },
+		parse: func(s *Server, value string) (func(), error) {
+			return parseRate(s.rateLimits.writes, value, true)
+		},
+		load: func(cfg *config.Config) string { return strconv.Itoa(cfg.Server.WriteRateLimitBurst) },
+	},
+	{
+		name: "rate_limit_key",
+		path: "server.rate_limit_key",
+		get:  func(s *Server) string { return s.rateLimits.key() },
+		parse: func(s *Server, value string) (func(), error) {
+			if value != rateLimitByIP && value != rateLimitByUser {
+				return nil, fmt.Errorf("expected ip or user")
+			}
+			return func() { s.rateLimits.setKey(value) }, nil
+		},
+		load: func(cfg *config.Config) string {
+			if cfg.Server.RateLimitKey == "" {
+				return rateLimitByIP
+			}
+			return cfg.Server.RateLimitKey
+		},
+	},
+	{
+		name: "slowlog_threshold_us",
+		path: "server.slowlog_threshold_us",
+		get: func(s *Server) string {
+			return strconv.FormatInt(time.Duration(s.slowlog.threshold.Load()).Microseconds(), 10)
+		},
+		parse: func(s *Server, value string) (func(), error) {
+			limit := math.MaxInt64 / int64(time.Microsecond)
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || n < -limit || n > limit {
+				return nil, fmt.Errorf("expected an integer between %d and %d", -limit, limit)
+			}
+			return func() { s.slowlog.threshold.Store(int64(time.Duration(n) * time.Microsecond)) }, nil
+		},
+		load: func(cfg *config.Config) string { return strconv.Itoa(cfg.Server.SlowlogThreshold) },
+	},
+	{
+		name: "slowlog_max_len",
+		path: "server.slowlog_max_len",
+		get:  func(s *Server) string { return strconv.FormatInt(s.slowlog.maxLen.Load(), 10) },
+		parse: func(s *Server, value string) (func(), error) {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("expected a non-negative integer")
+			}
+			return func() { s.slowlog.setMaxLen(n) }, nil
+		},
+		load: func(cfg *config.Config) string { return strconv.Itoa(cfg.Server.SlowlogMaxLen) },
+	},
+	{
+		name: "latency_monitor_threshold_ms",
+		path: "server.latency_monitor_threshold_ms",
+		get:  func(s *Server) string { return strconv.FormatInt(latency.Threshold().Milliseconds(), 10) },
+		parse: func(s *Server, value string) (func(), error) {
+			limit := math.MaxInt64 / int64(time.Millisecond)
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || n < 0 || n > limit {
+				return nil, fmt.Errorf("expected an integer between 0 and %d", limit)
+			}
+			return func() { latency.SetThreshold(time.Duration(n) * time.Millisecond) }, nil
+		},
+		load: func(cfg *config.Config) string { return strconv.Itoa(cfg.Server.LatencyMonitorThreshold) },
+	},
+	{
+		name: "max_connections",
+		path: "server.max_connections",
+		get:  func(s *Server) string { return strconv.FormatInt(s.maxConnections.Load(), 10) },
+		parse: func(s *Server, value string) (func(), error) {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("expected a non-negative integer")
+			}
+			return func() { s.maxConnections.Store(int64(n)) }, nil
+		},
+		load: func(cfg *config.Config) string { return strconv.Itoa(cfg.Server.MaxConnections) },
+	},
+	{
+		name: "auth_enabled",
+		path: "server.auth_enabled",
+		get:  func(s *Server) string { return strconv.FormatBool(s.authEnabled.Load()) },
+		parse: func(s *Server, value string) (func(), error) {
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("expected true or false")
+			}
+			return func() { s.authEnabled.Store(enabled) }, nil
+		},
+		load: func(cfg *config.Config) string { return strconv.FormatBool(cfg.Server.AuthEnabled) },
+	},
+	{
+		// password is never shown, and is written by CONFIG REWRITE as an
+		// argon2id hash in place of any password_file
+		name: "password",
+		path: "server.password",
+		get: func(s *Server) string {
+			if s.acl.getDefaultPassword() == "" {
+				return ""
+			}
+			return redacted
+		},
+		parse: func(s *Server, value string) (func(), error) {
+			switch {
+			case value == "":
+				return nil, fmt.Errorf("expected a password, or %s to remove it", noPassword)
+			case value == noPassword:
+				value = ""
+			case auth.IsHash(value):
+				if err := auth.Validate(value); err != nil {
+					return nil, err
+				}
+			}
+			return func() { s.acl.setDefaultPassword(value) }, nil
+		},
+		load: func(cfg *config.Config) string {
+			if cfg.Server.Password == "" {
+				return noPassword
+			}
+			return cfg.Server.Password
+		},
+		persist: func(s *Server) (string, error) {
+			password := s.acl.getDefaultPassword()
+			if password == "" || auth.IsHash(password) {
+				return password, nil
+			}
+			return auth.HashPassword(password)
+		},
+		overrides: []string{"server.password_file"},
+	},
+	{
+		name: "log_level",
+		path: "log.level",
+		get:  func(s *Server) string { return logger.GetLevel().String() },
+		parse: func(s *Server, value string) (func(), error) {
+			level, err := logger.ParseLevel(value)
+			if err != nil {
+				return nil, err
+			}
+			return func() { logger.SetLevel(level) }, nil
+		},
+		load: func(cfg *config.Config) string {
+			switch {
+			case cfg.Log.Debug:
+				return logger.LevelDebug.String()
+			case cfg.Log.Level == "":
+				return logger.LevelInfo.String()
+			}
+			return cfg.Log.Level
+		},
+		overrides: []string{"log.debug"},
+	},
+}
+
+func formatRate(limit ratelimit.Limit) string {
+	return strconv.FormatFloat(limit.Rate, 'f', -1, 64)
+}
+
+// parseRate parses a non-negative integer setting either the rate or the
+// burst of limiter.
+func parseRate(limiter *ratelimit.Limiter, value string, burst bool) (func(), error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("expected a non-negative integer")
+	}
+	return func() {
+		limit := limiter.Limit()
+		if burst {
+			limit.Burst = n
+		} else {
+			limit.Rate = float64(n)
+		}
+		limiter.SetLimit(limit)
+	}, nil
+}
+
+func lookupConfigParam(name string) (configParam, bool) {
+	for _, param := range configParams {
+		if strings.EqualFold(param.name, name) {
+			return param, true
+		}
+	}
+	return configParam{}, false
+}
+
+// setConfig validates settings, pairs of parameter names and values, then
+// applies them all, or none of them when one is invalid. It returns the names
+// of the parameters set.
+func (s *Server) setConfig(settings []string) ([]string, error) {
+	var names []string
+	var changes []func()
+	for i := 0; i < len(settings); i += 2 {
+		param, ok := lookupConfigParam(settings[i])
+		if !ok {
+			return nil, fmt.Errorf("unknown config parameter '%s'", settings[i])
+		}
+		apply, err := param.parse(s, settings[i+1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid value '%s' for '%s': %v", settings[i+1], param.name, err)
+		}
+		names = append(names, param.name)
+		changes = append(changes, apply)
+	}
+	for _, apply := range changes {
+		apply()
+	}
+	return names, nil
+}
+
+// rewriteConfig writes the settings changed by CONFIG SET to the config
+// file, keeping its comments.
+func (s *Server) rewriteConfig() error {
+	if s.configPath == "" {
+		return fmt.Errorf("the server was not started with a config file")
+	}
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	values := make(map[string]interface{})
+	var remove []string
+	for _, param := range configParams {
+		if !s.configChanged[param.name] {
+			continue
+		}
+		value := param.get(s)
+		if param.persist != nil {
+			var err error
+			if value, err = param.persist(s); err != nil {
+				return fmt.Errorf("failed to rewrite '%s': %v", param.name, err)
+			}
+		}
+		values[param.path] = yamlValue(value)
+		remove = append(remove, param.overrides...)
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	if err := config.Rewrite(s.configPath, values, remove); err != nil {
+		return err
+	}
+	clear(s.configChanged)
+	return nil
+}
+
+// yamlValue returns value as the integer or boolean it holds, so that it is
+// written unquoted.
+func yamlValue(value string) interface{} {
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return n
+	}
+	if value == "true" || value == "false" {
+		return value == "true"
+	}
+	return value
+}
+
+// ReloadConfig reads the config file again and applies the settings that
+// CONFIG SET can change and that were changed in the file. Nothing is applied
+// when a value is invalid. Other settings only change on restart.
+func (s *Server) ReloadConfig() error {
+	if s.configPath == "" {
+		return fmt.Errorf("the server was not started with a config file")
+	}
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	cfg, err := s.config.Reload()
+	if err != nil {
+		return err
+	}
+	var settings []string
+	for _, param := range configParams {
+		if value := param.load(cfg); value != param.load(s.config) {
+			settings = append(settings, param.name, value)
+		}
+	}
+	changed, err := s.setConfig(settings)
+	if err != nil {
+		return err
+	}
+	for _, name := range changed {
+		delete(s.configChanged, name)
+	}
+	s.config = cfg
+	if len(changed) > 0 {
+		logger.Infof("Reloaded %s from %s", strings.Join(changed, ", "), s.configPath)
+	}
+	return nil
+}
+
+type ConfigCommand struct {
+	server *Server
+}
+
+func (c *ConfigCommand) Execute(conn net.Conn, args []string) string {
+	switch strings.ToUpper(args[0]) {
+	case "GET":
+		if len(args) < 2 {
+			return "ERR wrong number of arguments for 'CONFIG GET' command"
+		}
+		var lines []string
+		for _, param := range configParams {
+			for _, pattern := range args[1:] {
+				if utils.GlobMatch(strings.ToLower(pattern), param.name) {
+					lines = append(lines, param.name, param.get(c.server))
+					break
+				}
+			}
+		}
+		return utils.FormatMultilineResponse(strings.Join(lines, "\n"))
+	case "SET":
+		if len(args) < 3 || len(args)%2 == 0 {
+			return "ERR wrong number of arguments for 'CONFIG SET' command"
+		}
+		changed, err := c.server.setConfig(args[1:])
+		if err != nil {
+			return fmt.Sprintf("ERR %v", err)
+		}
+		c.server.configMu.Lock()
+		for _, name := range changed {
+			c.server.configChanged[name] = true
+		}
+		c.server.configMu.Unlock()
+		return "OK"
+	case "REWRITE":
+		if len(args) != 1 {
+			return "ERR wrong number of arguments for 'CONFIG REWRITE' command"
+		}
+		if err := c.server.rewriteConfig(); err != nil {
+			return fmt.Sprintf("ERR %v", err)
+		}
+		return "OK"
+	}
+	return fmt.Sprintf("ERR unknown subcommand '%s' for 'CONFIG' command", args[0])
+}
+
+func (c *ConfigCommand) Replay(args []string, store *database.KVStore) error {
+	return nil // Runtime settings are not persisted in the AOF
+}
+
+func (c *ConfigCommand) GetCommandInfo() CommandDescription {
+	return CommandDescription{
+		Command:  "CONFIG",
+		Name:     "Configuration",
+		Syntax:   "CONFIG GET <pattern> [<pattern> ...] | CONFIG SET <parameter> <value> [<parameter> <value> ...] | CONFIG REWRITE",
+		HelpText: "Read or change server settings at runtime, and write the changes to the config file",
+		Arity:    -2,
+		Flags:    []CommandFlag{FlagAdmin, FlagNoScript},
+	}
+}
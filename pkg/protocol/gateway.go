@@ -0,0 +1,483 @@
+package protocol
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yashs662/SynchroDB/internal/config"
+	"github.com/yashs662/SynchroDB/internal/logger"
+	"github.com/yashs662/SynchroDB/internal/metrics"
+	"github.com/yashs662/SynchroDB/internal/utils"
+)
+
+// maxGatewayBodySize caps the body of gateway requests, which hold a value or
+// a command.
+const maxGatewayBodySize = 64 << 20
+
+// gatewayKeepAlive is how often an idle event stream is sent a comment, so
+// that proxies keep it open.
+const gatewayKeepAlive = 15 * time.Second
+
+// gatewayTimeout bounds reading a request and writing its response, or each
+// event of a stream. Idle keep-alive connections are closed after twice as
+// long.
+const gatewayTimeout = time.Minute
+
+// maxGatewayStreams bounds the event streams open at once. Every stream also
+// counts against max_connections.
+const maxGatewayStreams = 1024
+
+var gatewayRequests = metrics.NewCounterVec("synchrodb_http_requests_total", "HTTP gateway requests, by status code", "code")
+
+// gatewayAddr is the address of either end of a gateway request.
+type gatewayAddr struct {
+	network string
+	address string
+}
+
+func (a gatewayAddr) Network() string { return a.network }
+func (a gatewayAddr) String() string  { return a.address }
+
+// gatewayConn stands for an HTTP request wherever commands take the
+// connection they run for, so that requests are authenticated, authorized,
+// rate limited, audited and listed by CLIENT LIST like connections. It
+// carries no data, closing it ends the event stream of the request.
+type gatewayConn struct {
+	local    net.Addr
+	remote   net.Addr
+	tlsState *tls.ConnectionState
+	closed   chan struct{}
+	once     sync.Once
+}
+
+func newGatewayConn(r *http.Request) *gatewayConn {
+	local, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	if !ok {
+		local = gatewayAddr{network: "tcp"}
+	}
+	return &gatewayConn{
+		local:    local,
+		remote:   gatewayAddr{network: local.Network(), address: r.RemoteAddr},
+		tlsState: r.TLS,
+		closed:   make(chan struct{}),
+	}
+}
+
+func (c *gatewayConn) Read(b []byte) (int, error)  { return 0, io.EOF }
+func (c *gatewayConn) Write(b []byte) (int, error) { return len(b), nil }
+func (c *gatewayConn) LocalAddr() net.Addr         { return c.local }
+func (c *gatewayConn) RemoteAddr() net.Addr        { return c.remote }
+
+func (c *gatewayConn) Close() error {
+	c.once.Do(func() { close(c.closed) })
+	return nil
+}
+
+func (c *gatewayConn) SetDeadline(t time.Time) error      { return nil }
+func (c *gatewayConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *gatewayConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// ConnectionState returns the TLS state of the request, the zero value for
+// plaintext requests.
+func (c *gatewayConn) ConnectionState() tls.ConnectionState {
+	if c.tlsState == nil {
+		return tls.ConnectionState{}
+	}
+	return *c.tlsState
+}
+
+// statusRecorder keeps the status code of a response for the metrics and the
+// log.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController flush event streams.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// gatewayHandlerFunc handles a gateway request, running its commands for
+// conn.
+type gatewayHandlerFunc func(w http.ResponseWriter, r *http.Request, conn *gatewayConn)
+
+// serveGateway serves the HTTP gateway on every listener of configs until
+// Shutdown. TLS listeners use the certificate of the server.
+func (s *Server) serveGateway(configs []config.Listener, tlsConfig func() (*tls.Config, error)) error {
+	gatewayTLSConfig := func() (*tls.Config, error) {
+		cfg, err := tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		// alpn_protocols name the protocols of the other listeners
+		cfg = cfg.Clone()
+		cfg.NextProtos = []string{"http/1.1"}
+		return cfg, nil
+	}
+	var listeners []net.Listener
+	for _, lc := range configs {
+		listener, err := openListener(lc, "HTTP gateway", gatewayTLSConfig)
+		if err != nil {
+			for _, listener := range listeners {
+				listener.Close()
+			}
+			return err
+		}
+		listeners = append(listeners, listener)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /keys/{key...}", s.gateway(s.gatewayGetKey))
+	mux.HandleFunc("PUT /keys/{key...}", s.gateway(s.gatewayPutKey))
+	mux.HandleFunc("DELETE /keys/{key...}", s.gateway(s.gatewayDeleteKey))
+	mux.HandleFunc("POST /commands", s.gateway(s.gatewayCommand))
+	mux.HandleFunc("GET /subscribe", s.gateway(s.gatewaySubscribe))
+	httpServer := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       gatewayTimeout,
+		WriteTimeout:      gatewayTimeout,
+		IdleTimeout:       2 * gatewayTimeout,
+	}
+
+	s.listenerMutex.Lock()
+	s.httpServer = httpServer
+	s.listenerMutex.Unlock()
+
+	for _, listener := range listeners {
+		go func() {
+			if err := httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Errorf("HTTP gateway listener failed: %v", err)
+			}
+		}()
+	}
+	return nil
+}
+
+// gateway wraps handler with the handling shared by every gateway request:
+// it counts the request against max_connections, registers it as a client,
+// authenticates it and counts it in the metrics.
+func (s *Server) gateway(handler gatewayHandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		recorder := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			gatewayRequests.With(strconv.Itoa(recorder.status)).Inc()
+		}()
+		if !s.acquireConnection() {
+			logger.Warn("Connection limit reached, rejecting HTTP request", logger.ClientAddr(r.RemoteAddr))
+			writeJSON(recorder, http.StatusServiceUnavailable, gatewayReply{Error: "max number of clients reached"})
+			return
+		}
+		defer s.releaseConnection()
+
+		conn := newGatewayConn(r)
+		client := s.registerClient(conn)
+		defer func() {
+			s.conns.Delete(conn)
+			s.authGuard.forget(conn)
+		}()
+		defer func() {
+			client.log.Debug("HTTP request", logger.String("method", r.Method), logger.String("path", r.URL.Path), logger.Int("status", recorder.status))
+		}()
+
+		if reply := s.authenticateRequest(conn, r); reply != "" {
+			writeGatewayError(recorder, reply)
+			return
+		}
+		handler(recorder, r, conn)
+	}
+}
+
+// authenticateRequest authenticates conn with the credentials of r: HTTP
+// basic authentication as an ACL user, a bearer token holding the password
+// of the default user, or the verified client certificate. The credentials
+// go through AUTH, so failures are audited and lead to bans like on
+// connections. It returns the error reply of AUTH, empty on success and for
+// requests without credentials, which run as the default user when
+// authentication is disabled.
+func (s *Server) authenticateRequest(conn *gatewayConn, r *http.Request) string {
+	if r.TLS != nil {
+		s.authenticateClientCert(conn, *r.TLS)
+	}
+	var auth []string
+	if user, password, ok := r.BasicAuth(); ok {
+		auth = []string{"AUTH", user, password}
+	} else if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		auth = []string{"AUTH", token}
+	}
+	if auth == nil {
+		return ""
+	}
+	if reply := s.runCommand(conn, auth); reply != "OK" {
+		return reply
+	}
+	return ""
+}
+
+// gatewayReply is the JSON body of POST /commands and of failed requests.
+type gatewayReply struct {
+	Result *string `json:"result,omitempty"`
+	Error  string  `json:"error,omitempty"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(body)
+}
+
+// writeGatewayError answers with the error reply of a command and the status
+// code matching it.
+func writeGatewayError(w http.ResponseWriter, reply string) {
+	status := gatewayStatus(reply)
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="SynchroDB"`)
+	}
+	writeJSON(w, status, gatewayReply{Error: strings.TrimPrefix(reply, "ERR ")})
+}
+
+func gatewayStatus(reply string) int {
+	switch {
+	case reply == "ERR authentication required", strings.HasPrefix(reply, "ERR invalid username-password pair"):
+		return http.StatusUnauthorized
+	case reply == "ERR rate limited", strings.HasPrefix(reply, "ERR too many failed authentication attempts"):
+		return http.StatusTooManyRequests
+	case strings.Contains(reply, "has no permissions"), strings.Contains(reply, "no longer exists"):
+		return http.StatusForbidden
+	}
+	return http.StatusBadRequest
+}
+
+func isErrorReply(reply string) bool {
+	return strings.HasPrefix(reply, "ERR")
+}
+
+// readGatewayBody reads the body of r, answering with an error and returning
+// false when it cannot.
+func readGatewayBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxGatewayBodySize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeJSON(w, http.StatusRequestEntityTooLarge, gatewayReply{Error: fmt.Sprintf("body larger than %d bytes", tooLarge.Limit)})
+		} else {
+			writeJSON(w, http.StatusBadRequest, gatewayReply{Error: fmt.Sprintf("failed to read body: %v", err)})
+		}
+		return nil, false
+	}
+	return body, true
+}
+
+// gatewayGetKey answers GET /keys/{key} with the value of the key, and its
+// time to live in seconds in the X-TTL header when it expires.
+func (s *Server) gatewayGetKey(w http.ResponseWriter, r *http.Request, conn *gatewayConn) {
+	key := r.PathValue("key")
+	value := s.runCommand(conn, []string{"GET", key})
+	if isErrorReply(value) {
+		writeGatewayError(w, value)
+		return
+	}
+	// GET replies nil for missing keys and for keys holding "nil", TTL tells
+	// them apart
+	ttl := s.runCommand(conn, []string{"TTL", key})
+	if isErrorReply(ttl) {
+		writeGatewayError(w, ttl)
+		return
+	}
+	if ttl == "-2" {
+		writeJSON(w, http.StatusNotFound, gatewayReply{Error: "key does not exist"})
+		return
+	}
+	if ttl != "-1" {
+		w.Header().Set("X-TTL", ttl)
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.WriteString(w, value)
+}
+
+// gatewayPutKey sets the key of PUT /keys/{key} to the body, expiring after
+// the seconds of the X-TTL header when given.
+func (s *Server) gatewayPutKey(w http.ResponseWriter, r *http.Request, conn *gatewayConn) {
+	body, ok := readGatewayBody(w, r)
+	if !ok {
+		return
+	}
+	args := []string{"SET", r.PathValue("key"), string(body)}
+	if ttl := r.Header.Get("X-TTL"); ttl != "" {
+		args = append(args, "EX", ttl)
+	}
+	if reply := s.runCommand(conn, args); isErrorReply(reply) {
+		writeGatewayError(w, reply)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) gatewayDeleteKey(w http.ResponseWriter, r *http.Request, conn *gatewayConn) {
+	reply := s.runCommand(conn, []string{"DEL", r.PathValue("key")})
+	switch {
+	case isErrorReply(reply):
+		writeGatewayError(w, reply)
+	case reply == "0":
+		writeJSON(w, http.StatusNotFound, gatewayReply{Error: "key does not exist"})
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// gatewayCommandRequest is the body of POST /commands. Command is an array of
+// the command name and its arguments, or a line as sent over the TCP
+// protocol.
+type gatewayCommandRequest struct {
+	Command json.RawMessage `json:"command"`
+}
+
+func (req gatewayCommandRequest) parts() ([]string, error) {
+	var parts []string
+	if err := json.Unmarshal(req.Command, &parts); err == nil {
+		return parts, nil
+	}
+	var line string
+	if err := json.Unmarshal(req.Command, &line); err != nil {
+		return nil, errors.New("command must be an array of strings or a string")
+	}
+	return utils.SplitArgs(line)
+}
+
+// gatewayCommand runs the command of POST /commands, answering with its reply
+// as {"result": ...}, or {"error": ...} when it fails.
+func (s *Server) gatewayCommand(w http.ResponseWriter, r *http.Request, conn *gatewayConn) {
+	body, ok := readGatewayBody(w, r)
+	if !ok {
+		return
+	}
+	var req gatewayCommandRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, gatewayReply{Error: fmt.Sprintf("invalid JSON body: %v", err)})
+		return
+	}
+	parts, err := req.parts()
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, gatewayReply{Error: err.Error()})
+		return
+	}
+	if len(parts) == 0 {
+		writeJSON(w, http.StatusBadRequest, gatewayReply{Error: "invalid command"})
+		return
+	}
+	// Requests authenticate with their own credentials, and streams have
+	// their own endpoint
+	switch name := strings.ToUpper(parts[0]); name {
+	case "AUTH", "MONITOR", "SUBSCRIBE":
+		writeJSON(w, http.StatusBadRequest, gatewayReply{Error: fmt.Sprintf("'%s' is not available over HTTP", strings.ToLower(name))})
+		return
+	}
+
+	reply := s.runCommand(conn, parts)
+	if isErrorReply(reply) {
+		writeGatewayError(w, reply)
+		return
+	}
+	// Lines of replies such as MGET are joined with the delimiter of the TCP
+	// protocol, JSON keeps the newlines
+	reply = strings.ReplaceAll(reply, utils.MultilineResponseDelimiter, "\n")
+	writeJSON(w, http.StatusOK, gatewayReply{Result: &reply})
+}
+
+// gatewayEvent is the data of a message event sent by GET /subscribe.
+type gatewayEvent struct {
+	Channel string `json:"channel"`
+	Message string `json:"message"`
+}
+
+// gatewaySubscribe streams the messages published to the channels given as
+// channel query parameters, as server-sent events, until the client goes
+// away.
+func (s *Server) gatewaySubscribe(w http.ResponseWriter, r *http.Request, conn *gatewayConn) {
+	channels := r.URL.Query()["channel"]
+	if len(channels) == 0 {
+		writeJSON(w, http.StatusBadRequest, gatewayReply{Error: "the channel query parameter is required"})
+		return
+	}
+	if s.gatewayStreams.Add(1) > maxGatewayStreams {
+		s.gatewayStreams.Add(-1)
+		writeJSON(w, http.StatusServiceUnavailable, gatewayReply{Error: "max number of event streams reached"})
+		return
+	}
+	defer s.gatewayStreams.Add(-1)
+	if reply := s.runCommand(conn, append([]string{"SUBSCRIBE"}, channels...)); isErrorReply(reply) {
+		writeGatewayError(w, reply)
+		return
+	}
+	client := s.client(conn)
+	sub := s.pubsub.subscription(client)
+	defer s.pubsub.unsubscribe(client)
+
+	// The stream outlives the timeouts of the server, instead each event has
+	// to be written within gatewayTimeout
+	controller := http.NewResponseController(w)
+	controller.SetReadDeadline(time.Time{})
+	send := func(event string) bool {
+		if err := controller.SetWriteDeadline(time.Now().Add(gatewayTimeout)); err != nil {
+			return false
+		}
+		if _, err := io.WriteString(w, event); err != nil {
+			return false
+		}
+		return controller.Flush() == nil
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if !send("") {
+		return
+	}
+	keepAlive := time.NewTicker(gatewayKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		var event string
+		select {
+		case msg := <-sub.messages:
+			data, _ := json.Marshal(gatewayEvent{Channel: msg.channel, Message: msg.payload})
+			event = fmt.Sprintf("event: message\ndata: %s\n\n", data)
+		case <-keepAlive.C:
+			event = ": keep-alive\n\n"
+		case <-r.Context().Done():
+			return
+		case <-conn.closed:
+			return
+		case <-s.shutdownChan:
+			return
+		}
+		if !send(event) {
+			return
+		}
+	}
+}
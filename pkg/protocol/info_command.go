@@ -0,0 +1,173 @@
+package protocol
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/yashs662/SynchroDB/internal/utils"
+	"github.com/yashs662/SynchroDB/pkg/database"
+)
+
+// infoSection is a section of the INFO reply. fields returns its lines, each
+// formatted as name:value.
+type infoSection struct {
+	name   string
+	fields func(s *Server) []string
+}
+
+// infoSections are the sections of INFO in the order they are reported.
+// Field names are stable, pkg/client parses them.
+var infoSections = []infoSection{
+	{"server", (*Server).serverInfo},
+	{"clients", (*Server).clientsInfo},
+	{"memory", (*Server).memoryInfo},
+	{"persistence", (*Server).persistenceInfo},
+	{"stats", (*Server).statsInfo},
+	{"commandstats", (*Server).commandStatsInfo},
+	{"keyspace", (*Server).keyspaceInfo},
+}
+
+func (s *Server) serverInfo() []string {
+	uptime := time.Since(s.startTime)
+	return []string{
+		"go_version:" + runtime.Version(),
+		"os:" + runtime.GOOS,
+		"arch:" + runtime.GOARCH,
+		fmt.Sprintf("process_id:%d", os.Getpid()),
+		fmt.Sprintf("uptime_in_seconds:%d", int64(uptime.Seconds())),
+		fmt.Sprintf("uptime_in_days:%d", int64(uptime.Hours()/24)),
+	}
+}
+
+func (s *Server) clientsInfo() []string {
+	s.connMutex.Lock()
+	connected := s.connCount
+	s.connMutex.Unlock()
+	return []string{
+		fmt.Sprintf("connected_clients:%d", connected),
+		fmt.Sprintf("max_clients:%d", s.maxConnections.Load()),
+	}
+}
+
+func (s *Server) memoryInfo() []string {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return []string{
+		fmt.Sprintf("used_memory:%d", stats.HeapAlloc),
+		"used_memory_human:" + formatBytes(stats.HeapAlloc),
+		fmt.Sprintf("used_memory_sys:%d", stats.Sys),
+		"used_memory_sys_human:" + formatBytes(stats.Sys),
+		fmt.Sprintf("num_gc:%d", stats.NumGC),
+	}
+}
+
+func (s *Server) persistenceInfo() []string {
+	if !s.persistenceEnabled {
+		return []string{"aof_enabled:0"}
+	}
+	status := "ok"
+	if s.aofWriter.LastError() != nil {
+		status = "err"
+	}
+	return []string{
+		"aof_enabled:1",
+		fmt.Sprintf("aof_current_size:%d", s.aofWriter.Size()),
+		"aof_last_write_status:" + status,
+	}
+}
+
+func (s *Server) statsInfo() []string {
+	calls, rejected := commandTotals()
+	stats := s.store.Stats()
+	return []string{
+		fmt.Sprintf("total_connections_received:%d", connectionsReceived.Value()),
+		fmt.Sprintf("rejected_connections:%d", connectionsRejected.Value()),
+		fmt.Sprintf("total_commands_processed:%d", calls),
+		fmt.Sprintf("rejected_commands:%d", rejected),
+		fmt.Sprintf("total_net_input_bytes:%d", netInputBytes.Value()),
+		fmt.Sprintf("total_net_output_bytes:%d", netOutputBytes.Value()),
+		fmt.Sprintf("keyspace_hits:%d", stats.KeyspaceHits),
+		fmt.Sprintf("keyspace_misses:%d", stats.KeyspaceMisses),
+		fmt.Sprintf("expired_keys:%d", stats.ExpiredKeys),
+		fmt.Sprintf("auth_failures:%d", authFailures.Value()),
+		fmt.Sprintf("rate_limited_commands:%d", rateLimited.Value()),
+	}
+}
+
+func (s *Server) commandStatsInfo() []string {
+	var lines []string
+	eachCommandStat(func(command string, stat commandStat) {
+		calls, usec := stat.calls.Value(), int64(stat.duration.Sum()*1e6)
+		perCall := 0.0
+		if calls > 0 {
+			perCall = float64(usec) / float64(calls)
+		}
+		lines = append(lines, fmt.Sprintf("cmdstat_%s:calls=%d,usec=%d,usec_per_call=%.2f,rejected_calls=%d,failed_calls=%d",
+			command, calls, usec, perCall, stat.rejected.Value(), stat.failed.Value()))
+	})
+	return lines
+}
+
+// keyspaceInfo reports db0, the only database, when it holds keys.
+func (s *Server) keyspaceInfo() []string {
+	keys, expires := s.store.KeyCount()
+	if keys == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("db0:keys=%d,expires=%d", keys, expires)}
+}
+
+// formatBytes formats n with a binary unit suffix, as in 1.50M.
+func formatBytes(n uint64) string {
+	const units = "KMGTPE"
+	if n < 1024 {
+		return fmt.Sprintf("%dB", n)
+	}
+	value, unit := float64(n)/1024, 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	return fmt.Sprintf("%.2f%c", value, units[unit])
+}
+
+type InfoCommand struct {
+	server *Server
+}
+
+func (c *InfoCommand) Execute(conn net.Conn, args []string) string {
+	requested := make(map[string]bool)
+	for _, arg := range args {
+		requested[strings.ToLower(arg)] = true
+	}
+	all := len(args) == 0 || requested["all"] || requested["everything"] || requested["default"]
+
+	var sections []string
+	for _, section := range infoSections {
+		if !all && !requested[section.name] {
+			continue
+		}
+		lines := append([]string{"# " + strings.ToUpper(section.name[:1]) + section.name[1:]}, section.fields(c.server)...)
+		sections = append(sections, strings.Join(lines, "\n"))
+	}
+	return utils.FormatMultilineResponse(strings.Join(sections, "\n\n"))
+}
+
+func (c *InfoCommand) Replay(args []string, store *database.KVStore) error {
+	return nil // INFO does not modify the store
+}
+
+func (c *InfoCommand) GetCommandInfo() CommandDescription {
+	return CommandDescription{
+		Command:       "INFO",
+		Name:          "Info",
+		Syntax:        "INFO [<section> ...]",
+		HelpText:      "Report the state of the server, sections are server, clients, memory, persistence, stats, commandstats and keyspace, all of them by default",
+		Arity:         -1,
+		ACLCategories: []string{"@dangerous"},
+	}
+}
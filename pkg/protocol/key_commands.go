@@ -0,0 +1,436 @@
+package protocol
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yashs662/SynchroDB/pkg/database"
+)
+
+// deleteKeys removes keys and returns the number of keys that existed. It is
+// shared by DEL and UNLINK.
+func deleteKeys(server *Server, keys []string) string {
+	deleted := server.store.DelKeys(keys)
+	if deleted > 0 {
+		server.appendToAOF(append([]string{"DEL"}, keys...)...)
+	}
+	return strconv.Itoa(deleted)
+}
+
+// expireKey sets an absolute expiration on key. Every expiration command is
+// persisted as PEXPIREAT so that replaying the AOF restores the original
+// deadline instead of restarting the countdown.
+func expireKey(server *Server, key string, expireAt time.Time) string {
+	if !server.store.ExpireAt(key, expireAt) {
+		return "ERR key does not exist"
+	}
+	server.appendToAOF("PEXPIREAT", key, strconv.FormatInt(expireAt.UnixMilli(), 10))
+	return "OK"
+}
+
+// countExisting returns how many of keys exist, counting repeated keys once
+// per occurrence.
+func countExisting(store *database.KVStore, keys []string) string {
+	count := 0
+	for _, key := range keys {
+		if store.Exists(key) {
+			count++
+		}
+	}
+	return strconv.Itoa(count)
+}
+
+type ExistsCommand struct {
+	server *Server
+}
+
+func (c *ExistsCommand) Execute(conn net.Conn, args []string) string {
+	return countExisting(c.server.store, args)
+}
+
+func (c *ExistsCommand) Replay(args []string, store *database.KVStore) error {
+	return nil // No-op for replay
+}
+
+func (c *ExistsCommand) GetCommandInfo() CommandDescription {
+	return CommandDescription{
+		Command:       "EXISTS",
+		Name:          "Exists",
+		Syntax:        "EXISTS <key> [<key> ...]",
+		HelpText:      "Return how many of the given keys exist",
+		Arity:         -2,
+		Flags:         []CommandFlag{FlagReadOnly, FlagFast},
+		FirstKey:      1,
+		LastKey:       -1,
+		KeyStep:       1,
+		ACLCategories: []string{"@keyspace"},
+	}
+}
+
+type TypeCommand struct {
+	server *Server
+}
+
+func (c *TypeCommand) Execute(conn net.Conn, args []string) string {
+	if c.server.store.Exists(args[0]) {
+		return "string"
+	}
+	return "none"
+}
+
+func (c *TypeCommand) Replay(args []string, store *database.KVStore) error {
+	return nil // No-op for replay
+}
+
+func (c *TypeCommand) GetCommandInfo() CommandDescription {
+	return CommandDescription{
+		Command:       "TYPE",
+		Name:          "Type",
+		Syntax:        "TYPE <key>",
+		HelpText:      "Get the type of the value stored at a key, or none if it does not exist",
+		Arity:         2,
+		Flags:         []CommandFlag{FlagReadOnly, FlagFast},
+		FirstKey:      1,
+		LastKey:       1,
+		KeyStep:       1,
+		ACLCategories: []string{"@keyspace"},
+	}
+}
+
+type RenameCommand struct {
+	server *Server
+}
+
+func (c *RenameCommand) Execute(conn net.Conn, args []string) string {
+	src, dst := args[0], args[1]
+	if !c.server.store.Rename(src, dst) {
+		return "ERR no such key"
+	}
+	c.server.appendToAOF("RENAME", src, dst)
+	return "OK"
+}
+
+func (c *RenameCommand) Replay(args []string, store *database.KVStore) error {
+	if len(args) != 2 {
+		return fmt.Errorf("invalid arguments for 'RENAME' command")
+	}
+	store.Rename(args[0], args[1])
+	return nil
+}
+
+func (c *RenameCommand) GetCommandInfo() CommandDescription {
+	return CommandDescription{
+		Command:       "RENAME",
+		Name:          "Rename",
+		Syntax:        "RENAME <key> <newkey>",
+		HelpText:      "Rename a key, overwriting the destination if it exists",
+		Arity:         3,
+		Flags:         []CommandFlag{FlagWrite},
+		FirstKey:      1,
+		LastKey:       2,
+		KeyStep:       1,
+		ACLCategories: []string{"@keyspace"},
+	}
+}
+
+type CopyCommand struct {
+	server *Server
+}
+
+// parseCopyArgs validates the arguments of COPY and reports whether REPLACE
+// was given.
+func parseCopyArgs(args []string) (bool, error) {
+	switch {
+	case len(args) == 2:
+		return false, nil
+	case len(args) == 3 && strings.ToUpper(args[2]) == "REPLACE":
+		return true, nil
+	}
+	return false, errSyntax
+}
+
+func (c *CopyCommand) Execute(conn net.Conn, args []string) string {
+	replace, err := parseCopyArgs(args)
+	if err != nil {
+		return fmt.Sprintf("ERR %v", err)
+	}
+	src, dst := args[0], args[1]
+	if !c.server.store.Copy(src, dst, replace) {
+		return "0"
+	}
+	c.server.appendToAOF(append([]string{"COPY"}, args...)...)
+	return "1"
+}
+
+func (c *CopyCommand) Replay(args []string, store *database.KVStore) error {
+	replace, err := parseCopyArgs(args)
+	if err != nil {
+		return fmt.Errorf("invalid arguments for 'COPY' command")
+	}
+	store.Copy(args[0], args[1], replace)
+	return nil
+}
+
+func (c *CopyCommand) GetCommandInfo() CommandDescription {
+	return CommandDescription{
+		Command:       "COPY",
+		Name:          "Copy",
+		Syntax:        "COPY <source> <destination> [REPLACE]",
+		HelpText:      "Copy a key and its expiration, returning 1 if the copy was made",
+		Arity:         -3,
+		Flags:         []CommandFlag{FlagWrite},
+		FirstKey:      1,
+		LastKey:       2,
+		KeyStep:       1,
+		ACLCategories: []string{"@keyspace"},
+	}
+}
+
+type PersistCommand struct {
+	server *Server
+}
+
+func (c *PersistCommand) Execute(conn net.Conn, args []string) string {
+	key := args[0]
+	if !c.server.store.Persist(key) {
+		return "0"
+	}
+	c.server.appendToAOF("PERSIST", key)
+	return "1"
+}
+
+func (c *PersistCommand) Replay(args []string, store *database.KVStore) error {
+	if len(args) != 1 {
+		return fmt.Errorf("invalid arguments for 'PERSIST' command")
+	}
+	store.Persist(args[0])
+	return nil
+}
+
+func (c *PersistCommand) GetCommandInfo() CommandDescription {
+	return CommandDescription{
+		Command:       "PERSIST",
+		Name:          "Persist",
+		Syntax:        "PERSIST <key>",
+		HelpText:      "Remove the expiration of a key, returning 1 if an expiration was removed",
+		Arity:         2,
+		Flags:         []CommandFlag{FlagWrite, FlagFast},
+		FirstKey:      1,
+		LastKey:       1,
+		KeyStep:       1,
+		ACLCategories: []string{"@keyspace"},
+	}
+}
+
+type PExpireCommand struct {
+	server *Server
+}
+
+func (c *PExpireCommand) Execute(conn net.Conn, args []string) string {
+	expireAt, err := parseExpiration("PX", args[1])
+	if err != nil {
+		return fmt.Sprintf("ERR %v", err)
+	}
+	return expireKey(c.server, args[0], expireAt)
+}
+
+func (c *PExpireCommand) Replay(args []string, store *database.KVStore) error {
+	return nil // Persisted as PEXPIREAT
+}
+
+func (c *PExpireCommand) GetCommandInfo() CommandDescription {
+	return CommandDescription{
+		Command:       "PEXPIRE",
+		Name:          "Expire in Milliseconds",
+		Syntax:        "PEXPIRE <key> <milliseconds>",
+		HelpText:      "Set a key's time to live in milliseconds",
+		Arity:         3,
+		Flags:         []CommandFlag{FlagWrite, FlagFast},
+		FirstKey:      1,
+		LastKey:       1,
+		KeyStep:       1,
+		ACLCategories: []string{"@keyspace"},
+	}
+}
+
+type ExpireAtCommand struct {
+	server *Server
+}
+
+func (c *ExpireAtCommand) Execute(conn net.Conn, args []string) string {
+	expireAt, err := parseExpiration("EXAT", args[1])
+	if err != nil {
+		return fmt.Sprintf("ERR %v", err)
+	}
+	return expireKey(c.server, args[0], expireAt)
+}
+
+func (c *ExpireAtCommand) Replay(args []string, store *database.KVStore) error {
+	return nil // Persisted as PEXPIREAT
+}
+
+func (c *ExpireAtCommand) GetCommandInfo() CommandDescription {
+	return CommandDescription{
+		Command:       "EXPIREAT",
+		Name:          "Expire At",
+		Syntax:        "EXPIREAT <key> <unix-seconds>",
+		HelpText:      "Set a key to expire at a unix timestamp in seconds",
+		Arity:         3,
+		Flags:         []CommandFlag{FlagWrite, FlagFast},
+		FirstKey:      1,
+		LastKey:       1,
+		KeyStep:       1,
+		ACLCategories: []string{"@keyspace"},
+	}
+}
+
+type PExpireAtCommand struct {
+	server *Server
+}
+
+func (c *PExpireAtCommand) Execute(conn net.Conn, args []string) string {
+	expireAt, err := parseExpiration("PXAT", args[1])
+	if err != nil {
+		return fmt.Sprintf("ERR %v", err)
+	}
+	return expireKey(c.server, args[0], expireAt)
+}
+
+func (c *PExpireAtCommand) Replay(args []string, store *database.KVStore) error {
+	if len(args) != 2 {
+		return fmt.Errorf("invalid arguments for 'PEXPIREAT' command")
+	}
+	expireAt, err := parseExpiration("PXAT", args[1])
+	if err != nil {
+		return fmt.Errorf("invalid expiration value: %s", args[1])
+	}
+	store.ExpireAt(args[0], expireAt)
+	return nil
+}
+
+func (c *PExpireAtCommand) GetCommandInfo() CommandDescription {
+	return CommandDescription{
+		Command:       "PEXPIREAT",
+		Name:          "Expire At Milliseconds",
+		Syntax:        "PEXPIREAT <key> <unix-milliseconds>",
+		HelpText:      "Set a key to expire at a unix timestamp in milliseconds",
+		Arity:         3,
+		Flags:         []CommandFlag{FlagWrite, FlagFast},
+		FirstKey:      1,
+		LastKey:       1,
+		KeyStep:       1,
+		ACLCategories: []string{"@keyspace"},
+	}
+}
+
+type PTTLCommand struct {
+	server *Server
+}
+
+func (c *PTTLCommand) Execute(conn net.Conn, args []string) string {
+	return strconv.FormatInt(c.server.store.PTTL(args[0]), 10)
+}
+
+func (c *PTTLCommand) Replay(args []string, store *database.KVStore) error {
+	return nil // No-op for replay
+}
+
+func (c *PTTLCommand) GetCommandInfo() CommandDescription {
+	return CommandDescription{
+		Command:       "PTTL",
+		Name:          "Time to Live in Milliseconds",
+		Syntax:        "PTTL <key>",
+		HelpText:      "Get the time to live of a key in milliseconds, -1 if it has no expiration and -2 if it does not exist",
+		Arity:         2,
+		Flags:         []CommandFlag{FlagReadOnly, FlagFast},
+		FirstKey:      1,
+		LastKey:       1,
+		KeyStep:       1,
+		ACLCategories: []string{"@keyspace"},
+	}
+}
+
+type RandomKeyCommand struct {
+	server *Server
+}
+
+func (c *RandomKeyCommand) Execute(conn net.Conn, args []string) string {
+	key, found := c.server.store.RandomKey()
+	if !found {
+		return "nil"
+	}
+	return key
+}
+
+func (c *RandomKeyCommand) Replay(args []string, store *database.KVStore) error {
+	return nil // No-op for replay
+}
+
+func (c *RandomKeyCommand) GetCommandInfo() CommandDescription {
+	return CommandDescription{
+		Command:       "RANDOMKEY",
+		Name:          "Random Key",
+		Syntax:        "RANDOMKEY",
+		HelpText:      "Return a random key from the database",
+		Arity:         1,
+		Flags:         []CommandFlag{FlagReadOnly},
+		ACLCategories: []string{"@keyspace"},
+	}
+}
+
+type UnlinkCommand struct {
+	server *Server
+}
+
+func (c *UnlinkCommand) Execute(conn net.Conn, args []string) string {
+	return deleteKeys(c.server, args)
+}
+
+func (c *UnlinkCommand) Replay(args []string, store *database.KVStore) error {
+	return nil // Persisted as DEL
+}
+
+func (c *UnlinkCommand) GetCommandInfo() CommandDescription {
+	return CommandDescription{
+		Command:       "UNLINK",
+		Name:          "Unlink",
+		Syntax:        "UNLINK <key> [<key> ...]",
+		HelpText:      "Delete one or more keys and return how many were removed",
+		Arity:         -2,
+		Flags:         []CommandFlag{FlagWrite, FlagFast},
+		FirstKey:      1,
+		LastKey:       -1,
+		KeyStep:       1,
+		ACLCategories: []string{"@keyspace"},
+	}
+}
+
+type TouchCommand struct {
+	server *Server
+}
+
+func (c *TouchCommand) Execute(conn net.Conn, args []string) string {
+	return countExisting(c.server.store, args)
+}
+
+func (c *TouchCommand) Replay(args []string, store *database.KVStore) error {
+	return nil // No-op for replay
+}
+
+func (c *TouchCommand) GetCommandInfo() CommandDescription {
+	return CommandDescription{
+		Command:       "TOUCH",
+		Name:          "Touch",
+		Syntax:        "TOUCH <key> [<key> ...]",
+		HelpText:      "Return how many of the given keys exist",
+		Arity:         -2,
+		Flags:         []CommandFlag{FlagReadOnly, FlagFast},
+		FirstKey:      1,
+		LastKey:       -1,
+		KeyStep:       1,
+		ACLCategories: []string{"@keyspace"},
+	}
+}
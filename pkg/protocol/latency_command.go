@@ -0,0 +1,110 @@
+package protocol
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yashs662/SynchroDB/internal/latency"
+	"github.com/yashs662/SynchroDB/internal/utils"
+	"github.com/yashs662/SynchroDB/pkg/database"
+)
+
+// latencyAdvice explains what LATENCY DOCTOR suggests for spikes of an event.
+var latencyAdvice = map[string]string{
+	latency.EventCommand:     "Slow commands. Find them with SLOWLOG GET and avoid commands that scan the whole keyspace, such as KEYS, on large data sets.",
+	latency.EventFastCommand: "Commands that normally run in constant time were slow. The host is likely overloaded, swapping or sharing its CPU with noisy neighbours.",
+	latency.EventAOFWrite:    "Slow writes to the AOF file. Check the disk for contention and consider a faster disk for persistent_aof_path.",
+	latency.EventExpireCycle: "Slow expiration cycles, many keys expire at the same time. Spread their expirations by adding random jitter to the TTLs.",
+}
+
+type LatencyCommand struct {
+	server *Server
+}
+
+func (c *LatencyCommand) Execute(conn net.Conn, args []string) string {
+	switch strings.ToUpper(args[0]) {
+	case "LATEST":
+		var lines []string
+		for _, stats := range latency.Latest() {
+			lines = append(lines, fmt.Sprintf("event=%s time=%d latest_ms=%d max_ms=%d",
+				stats.Event, stats.Latest.Time.Unix(), stats.Latest.Latency.Milliseconds(), stats.Max.Milliseconds()))
+		}
+		return utils.FormatMultilineResponse(strings.Join(lines, "\n"))
+	case "HISTORY":
+		if len(args) != 2 {
+			return "ERR wrong number of arguments for 'LATENCY HISTORY' command"
+		}
+		var lines []string
+		for _, sample := range latency.History(args[1]) {
+			lines = append(lines, fmt.Sprintf("time=%d latency_ms=%d", sample.Time.Unix(), sample.Latency.Milliseconds()))
+		}
+		return utils.FormatMultilineResponse(strings.Join(lines, "\n"))
+	case "RESET":
+		return strconv.Itoa(latency.Reset(args[1:]...))
+	case "DOCTOR":
+		return utils.FormatMultilineResponse(latencyDoctor())
+	}
+	return fmt.Sprintf("ERR unknown subcommand '%s' for 'LATENCY' command", args[0])
+}
+
+// latencyDoctor describes the recorded spikes of every event and what may
+// cause them.
+func latencyDoctor() string {
+	threshold := latency.Threshold()
+	if threshold <= 0 {
+		return "The latency monitor is disabled. Enable it with CONFIG SET latency_monitor_threshold_ms <milliseconds>, or latency_monitor_threshold_ms in the config file."
+	}
+	latest := latency.Latest()
+	if len(latest) == 0 {
+		return fmt.Sprintf("No latency spikes of %s or more were recorded.", threshold)
+	}
+
+	lines := []string{fmt.Sprintf("Latency spikes of %s or more were recorded for %d event types.", threshold, len(latest)), ""}
+	for _, stats := range latest {
+		samples := latency.History(stats.Event)
+		if len(samples) == 0 {
+			continue // Reset by a concurrent LATENCY RESET
+		}
+		var total time.Duration
+		for _, sample := range samples {
+			total += sample.Latency
+		}
+		average := total / time.Duration(len(samples))
+		var deviation time.Duration
+		for _, sample := range samples {
+			deviation += (sample.Latency - average).Abs()
+		}
+		deviation /= time.Duration(len(samples))
+
+		line := fmt.Sprintf("%s: %d latency spikes (average %s, mean deviation %s", stats.Event, len(samples),
+			average.Round(time.Millisecond), deviation.Round(time.Millisecond))
+		if len(samples) > 1 {
+			period := samples[len(samples)-1].Time.Sub(samples[0].Time) / time.Duration(len(samples)-1)
+			line += fmt.Sprintf(", one every %s", period)
+		}
+		line += fmt.Sprintf("). Worst spike %s, latest at %s.", stats.Max.Round(time.Millisecond), stats.Latest.Time.Format(time.RFC3339))
+		lines = append(lines, line)
+		if advice, ok := latencyAdvice[stats.Event]; ok {
+			lines = append(lines, "  "+advice)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (c *LatencyCommand) Replay(args []string, store *database.KVStore) error {
+	return nil // The latency monitor is not persisted in the AOF
+}
+
+func (c *LatencyCommand) GetCommandInfo() CommandDescription {
+	return CommandDescription{
+		Command:  "LATENCY",
+		Name:     "Latency",
+		Syntax:   "LATENCY LATEST | LATENCY HISTORY <event> | LATENCY RESET [<event> ...] | LATENCY DOCTOR",
+		HelpText: "Show the latency spikes of server events recorded once latency_monitor_threshold_ms is set",
+		Arity:    -2,
+		Flags:    []CommandFlag{FlagAdmin, FlagNoScript},
+	}
+}
@@ -0,0 +1,194 @@
+package protocol
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/yashs662/SynchroDB/internal/config"
+	"github.com/yashs662/SynchroDB/internal/logger"
+)
+
+// Listener types accepted in the listeners config.
+const (
+	ListenerTLS  = "tls"
+	ListenerTCP  = "tcp"
+	ListenerUnix = "unix"
+)
+
+// defaultSocketMode applies to Unix sockets without socket_mode.
+const defaultSocketMode fs.FileMode = 0600
+
+// listenerConfigs returns every listener to open: the TLS listener on address,
+// when set, followed by the ones under listeners.
+func listenerConfigs(cfg *config.Config) []config.Listener {
+	var listeners []config.Listener
+	if cfg.Server.Address != "" {
+		listeners = append(listeners, config.Listener{Type: ListenerTLS, Address: cfg.Server.Address})
+	}
+	return append(listeners, cfg.Server.Listeners...)
+}
+
+// openListeners opens every configured listener. tlsConfig is only built,
+// and the certificates only loaded, when a TLS listener is configured. When
+// one listener fails the ones already opened are closed.
+func (s *Server) openListeners(cfg *config.Config, tlsConfig func() (*tls.Config, error)) ([]net.Listener, error) {
+	configs := listenerConfigs(cfg)
+	if len(configs) == 0 {
+		return nil, errors.New("no listeners are configured, set address or listeners")
+	}
+
+	var listeners []net.Listener
+	closeAll := func() {
+		for _, listener := range listeners {
+			listener.Close()
+		}
+	}
+	for _, lc := range configs {
+		listener, err := openListener(lc, "server", tlsConfig)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
+
+// openListener opens the listener of lc for service, named in the log.
+func openListener(lc config.Listener, service string, tlsConfig func() (*tls.Config, error)) (net.Listener, error) {
+	switch lc.Type {
+	case ListenerTLS, "":
+		cfg, err := tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		listener, err := tls.Listen("tcp", lc.Address, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start TLS listener: %w", err)
+		}
+		logger.Infof("Secure %s is listening on %s", service, lc.Address)
+		return listener, nil
+	case ListenerTCP:
+		if err := checkLoopback(lc.Address); err != nil {
+			return nil, err
+		}
+		listener, err := net.Listen("tcp", lc.Address)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start TCP listener: %w", err)
+		}
+		logger.Infof("Plaintext %s is listening on %s", service, lc.Address)
+		return listener, nil
+	case ListenerUnix:
+		return listenUnix(lc, service)
+	}
+	return nil, fmt.Errorf("invalid listener type %q, expected tls, tcp or unix", lc.Type)
+}
+
+// checkLoopback rejects plaintext TCP addresses that are reachable from other
+// hosts.
+func checkLoopback(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid TCP listener address %q: %w", address, err)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("plaintext TCP listener %q must be bound to a loopback address", address)
+}
+
+// listenUnix listens on a Unix socket, replacing a stale socket left behind by
+// a previous run, and applies socket_mode to the socket file.
+func listenUnix(lc config.Listener, service string) (net.Listener, error) {
+	mode := defaultSocketMode
+	if lc.SocketMode != "" {
+		parsed, err := strconv.ParseUint(lc.SocketMode, 8, 32)
+		if err != nil || parsed > 0777 {
+			return nil, fmt.Errorf("invalid socket_mode %q, expected octal permissions such as 0660", lc.SocketMode)
+		}
+		mode = fs.FileMode(parsed)
+	}
+
+	if info, err := os.Stat(lc.Address); err == nil {
+		if info.Mode()&fs.ModeSocket == 0 {
+			return nil, fmt.Errorf("unix socket path %s exists and is not a socket", lc.Address)
+		}
+		if err := os.Remove(lc.Address); err != nil {
+			return nil, fmt.Errorf("failed to remove stale unix socket: %w", err)
+		}
+	}
+
+	listener, err := net.Listen("unix", lc.Address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start unix socket listener: %w", err)
+	}
+	if err := os.Chmod(lc.Address, mode); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to set unix socket permissions: %w", err)
+	}
+	logger.Infof("%s%s is listening on unix socket %s", strings.ToUpper(service[:1]), service[1:], lc.Address)
+	return listener, nil
+}
+
+// serve accepts connections on listener until it is closed.
+func (s *Server) serve(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		select {
+		case <-s.shutdownChan:
+			if conn != nil {
+				conn.Close()
+			}
+			return
+		default:
+		}
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			logger.Warnf("Failed to accept connection: %v", err)
+			continue
+		}
+
+		if !s.acquireConnection() {
+			logger.Warn("Connection limit reached, rejecting connection", logger.ClientAddr(conn.RemoteAddr().String()))
+			conn.Close()
+			continue
+		}
+		connectionsReceived.Inc()
+
+		logger.Debug("Accepted connection", logger.ClientAddr(conn.RemoteAddr().String()))
+
+		go s.handleConnection(s.registerClient(conn))
+	}
+}
+
+// acquireConnection counts a new connection, or a gateway request, against
+// max_connections. It reports false, counting the rejection, when the limit
+// is reached.
+func (s *Server) acquireConnection() bool {
+	s.connMutex.Lock()
+	defer s.connMutex.Unlock()
+	if maxConnections := int(s.maxConnections.Load()); maxConnections > 0 && s.connCount >= maxConnections {
+		connectionsRejected.Inc()
+		return false
+	}
+	s.connCount++
+	return true
+}
+
+// releaseConnection uncounts a connection counted by acquireConnection.
+func (s *Server) releaseConnection() {
+	s.connMutex.Lock()
+	s.connCount--
+	s.connMutex.Unlock()
+}
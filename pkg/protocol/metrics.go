@@ -0,0 +1,59 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/yashs662/SynchroDB/internal/logger"
+	"github.com/yashs662/SynchroDB/internal/metrics"
+)
+
+// registerGauges exports the state of the server read when metrics are
+// scraped.
+func (s *Server) registerGauges() {
+	metrics.NewGaugeFunc("synchrodb_connected_clients", "Open client connections", func() float64 {
+		s.connMutex.Lock()
+		defer s.connMutex.Unlock()
+		return float64(s.connCount)
+	})
+	metrics.NewGaugeFunc("synchrodb_max_clients", "The max_connections setting, 0 when unlimited", func() float64 {
+		return float64(s.maxConnections.Load())
+	})
+	metrics.NewGaugeFunc("synchrodb_keys", "Keys in the store", func() float64 {
+		keys, _ := s.store.KeyCount()
+		return float64(keys)
+	})
+	metrics.NewGaugeFunc("synchrodb_keys_with_expiration", "Keys in the store with an expiration", func() float64 {
+		_, expires := s.store.KeyCount()
+		return float64(expires)
+	})
+	metrics.NewGaugeFunc("synchrodb_uptime_seconds", "Time since the server started", func() float64 {
+		return time.Since(s.startTime).Seconds()
+	})
+}
+
+// serveMetrics serves /metrics over plain HTTP on address until Shutdown.
+func (s *Server) serveMetrics(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to start metrics listener: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	httpServer := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	s.listenerMutex.Lock()
+	s.metricsServer = httpServer
+	s.listenerMutex.Unlock()
+
+	logger.Infof("Metrics are served on http://%s/metrics", address)
+	go func() {
+		if err := httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Errorf("Metrics listener failed: %v", err)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,132 @@
+package protocol
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/yashs662/SynchroDB/internal/logger"
+	"github.com/yashs662/SynchroDB/internal/utils"
+	"github.com/yashs662/SynchroDB/pkg/database"
+)
+
+// monitorBuffer is how many lines a monitor may fall behind before it is
+// disconnected.
+const monitorBuffer = 1024
+
+// monitors are the connections that ran MONITOR. Every command is sent to
+// each of them through a buffered channel, so a slow monitor never holds back
+// the commands.
+type monitors struct {
+	// count lets handleCommand skip formatting lines when nobody monitors
+	count atomic.Int32
+
+	mu    sync.RWMutex
+	lines map[*client]chan string
+}
+
+func newMonitors() *monitors {
+	return &monitors{lines: make(map[*client]chan string)}
+}
+
+func (m *monitors) active() bool {
+	return m.count.Load() > 0
+}
+
+func (m *monitors) add(c *client) <-chan string {
+	lines := make(chan string, monitorBuffer)
+	m.mu.Lock()
+	m.lines[c] = lines
+	m.count.Store(int32(len(m.lines)))
+	m.mu.Unlock()
+	return lines
+}
+
+func (m *monitors) remove(c *client) {
+	m.mu.Lock()
+	delete(m.lines, c)
+	m.count.Store(int32(len(m.lines)))
+	m.mu.Unlock()
+}
+
+// feed sends line to every monitor, disconnecting the ones too far behind.
+func (m *monitors) feed(line string) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for c, lines := range m.lines {
+		select {
+		case lines <- line:
+		default:
+			c.log.Warn("Disconnecting monitor, it is too many commands behind", logger.Int("buffer", monitorBuffer))
+			c.conn.Close()
+		}
+	}
+}
+
+// monitorLine formats a command for the monitors as
+// "<unix time>.<microseconds> [<db> <addr>] <command> <args>".
+func monitorLine(conn net.Conn, info CommandDescription, args []string) string {
+	now := time.Now()
+	return fmt.Sprintf("%d.%06d [0 %s] %s", now.Unix(), now.Nanosecond()/1000, conn.RemoteAddr(), utils.JoinArgs(redactArgs(info, args)))
+}
+
+// streamMonitor turns the connection of c into a stream of the commands run
+// by every client, until it is closed. Input from the monitor is discarded.
+func (s *Server) streamMonitor(c *client, reader *bufio.Reader) {
+	lines := s.monitors.add(c)
+	defer s.monitors.remove(c)
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, err := reader.ReadString('\n'); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case line := <-lines:
+			if err := c.write(line + "\n"); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		case <-s.shutdownChan:
+			return
+		}
+	}
+}
+
+type MonitorCommand struct {
+	server *Server
+}
+
+func (c *MonitorCommand) Execute(conn net.Conn, args []string) string {
+	client := c.server.client(conn)
+	if client == nil {
+		return "ERR MONITOR is only available to client connections"
+	}
+	client.monitoring.Store(true)
+	return "OK"
+}
+
+func (c *MonitorCommand) Replay(args []string, store *database.KVStore) error {
+	return nil // MONITOR does not modify the store
+}
+
+func (c *MonitorCommand) GetCommandInfo() CommandDescription {
+	return CommandDescription{
+		Command:  "MONITOR",
+		Name:     "Monitor",
+		Syntax:   "MONITOR",
+		HelpText: "Stream every command run by the server to this connection, which accepts no further commands",
+		Arity:    1,
+		Flags:    []CommandFlag{FlagAdmin, FlagNoScript},
+	}
+}
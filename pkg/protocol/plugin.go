@@ -0,0 +1,80 @@
+package protocol
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/yashs662/SynchroDB/pkg/database"
+)
+
+// customCommand wraps a command registered through RegisterCommand. Built-in
+// commands write their own AOF entries, custom commands cannot reach the AOF
+// writer, so successful calls of commands flagged as write are persisted here
+// as they were received. On startup the entries are handed back to the
+// command's Replay method.
+type customCommand struct {
+	Command
+	server *Server
+}
+
+func (c *customCommand) Execute(conn net.Conn, args []string) string {
+	reply := c.Command.Execute(conn, args)
+	info := c.GetCommandInfo()
+	if info.HasFlag(FlagWrite) && !strings.HasPrefix(reply, "ERR") {
+		c.server.appendToAOF(append([]string{info.Command}, args...)...)
+	}
+	return reply
+}
+
+// RegisterCommand adds a custom command to the server. It must be called
+// before Start. The command's GetCommandInfo describes it: the name it is
+// invoked by, its help text shown by HELP, its arity, which is checked before
+// Execute is called, its flags and its key positions. Successful calls of
+// commands flagged FlagWrite are appended to the AOF and replayed through
+// the command's Replay method on startup. Authentication applies to custom
+// commands exactly as it does to the built-in ones.
+func (s *Server) RegisterCommand(cmd Command) error {
+	if s.started.Load() {
+		return fmt.Errorf("commands must be registered before the server is started")
+	}
+
+	info := cmd.GetCommandInfo()
+	name := strings.ToUpper(info.Command)
+	switch {
+	case name == "" || strings.ContainsAny(name, " \t\r\n"):
+		return fmt.Errorf("invalid command name %q", info.Command)
+	case info.HasFlag(FlagWrite) && info.HasFlag(FlagReadOnly):
+		return fmt.Errorf("command '%s' cannot be both write and readonly", name)
+	case info.FirstKey < 0 || (info.FirstKey > 0 && info.LastKey > 0 && info.LastKey < info.FirstKey):
+		return fmt.Errorf("command '%s' has invalid key positions", name)
+	}
+	if _, exists := s.commandRegistry.Get(name); exists {
+		return fmt.Errorf("command '%s' is already registered", name)
+	}
+
+	wrapped := &customCommand{Command: cmd, server: s}
+	s.commandRegistry.Register(name, wrapped)
+	s.customCommands = append(s.customCommands, wrapped)
+	return nil
+}
+
+// commands returns the built-in commands followed by the custom ones, in the
+// order they are listed by HELP.
+func (s *Server) commands() []Command {
+	return append(AllCommands(s), s.customCommands...)
+}
+
+// lookupCommand returns the command registered under name along with its
+// description.
+func (s *Server) lookupCommand(name string) (database.Command, CommandDescription, bool) {
+	cmd, exists := s.commandRegistry.Get(name)
+	if !exists {
+		return nil, CommandDescription{}, false
+	}
+	var info CommandDescription
+	if described, ok := cmd.(Command); ok {
+		info = described.GetCommandInfo()
+	}
+	return cmd, info, true
+}
@@ -0,0 +1,182 @@
+package protocol
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/yashs662/SynchroDB/internal/logger"
+	"github.com/yashs662/SynchroDB/internal/utils"
+	"github.com/yashs662/SynchroDB/pkg/database"
+)
+
+// subscriberBuffer is how many messages a subscriber may fall behind before
+// it is disconnected.
+const subscriberBuffer = 1024
+
+// message is a message published to a channel.
+type message struct {
+	channel string
+	payload string
+}
+
+// subscription is the channels a client subscribed to and the messages
+// published to them, buffered so that a slow subscriber never holds back
+// PUBLISH.
+type subscription struct {
+	channels map[string]bool
+	messages chan message
+}
+
+// pubsub routes the messages sent by PUBLISH to the subscribed clients, which
+// are connections that ran SUBSCRIBE or streaming HTTP requests.
+type pubsub struct {
+	mu            sync.RWMutex
+	subscriptions map[*client]*subscription
+}
+
+func newPubsub() *pubsub {
+	return &pubsub{subscriptions: make(map[*client]*subscription)}
+}
+
+// subscribe adds channels to the subscription of c, creating it, and returns
+// the number of channels c is subscribed to after each of them.
+func (p *pubsub) subscribe(c *client, channels []string) []int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	sub, ok := p.subscriptions[c]
+	if !ok {
+		sub = &subscription{channels: make(map[string]bool), messages: make(chan message, subscriberBuffer)}
+		p.subscriptions[c] = sub
+	}
+	counts := make([]int, len(channels))
+	for i, channel := range channels {
+		sub.channels[channel] = true
+		counts[i] = len(sub.channels)
+	}
+	return counts
+}
+
+// subscription returns the subscription of c, nil when it has none.
+func (p *pubsub) subscription(c *client) *subscription {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.subscriptions[c]
+}
+
+func (p *pubsub) unsubscribe(c *client) {
+	p.mu.Lock()
+	delete(p.subscriptions, c)
+	p.mu.Unlock()
+}
+
+// publish sends payload to every client subscribed to channel, disconnecting
+// the ones too far behind, and returns how many received it.
+func (p *pubsub) publish(channel, payload string) int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	received := 0
+	for c, sub := range p.subscriptions {
+		if !sub.channels[channel] {
+			continue
+		}
+		select {
+		case sub.messages <- message{channel: channel, payload: payload}:
+			received++
+		default:
+			c.log.Warn("Disconnecting subscriber, it is too many messages behind", logger.Int("buffer", subscriberBuffer))
+			c.conn.Close()
+		}
+	}
+	return received
+}
+
+// streamMessages turns the connection of c into a stream of the messages
+// published to its channels, one "message <channel> <payload>" line each,
+// until it is closed. Input from the subscriber is discarded.
+func (s *Server) streamMessages(c *client, sub *subscription, reader *bufio.Reader) {
+	defer s.pubsub.unsubscribe(c)
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, err := reader.ReadString('\n'); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case msg := <-sub.messages:
+			if err := c.write(utils.JoinArgs([]string{"message", msg.channel, msg.payload}) + "\n"); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		case <-s.shutdownChan:
+			return
+		}
+	}
+}
+
+type PublishCommand struct {
+	server *Server
+}
+
+func (c *PublishCommand) Execute(conn net.Conn, args []string) string {
+	return strconv.Itoa(c.server.pubsub.publish(args[0], args[1]))
+}
+
+func (c *PublishCommand) Replay(args []string, store *database.KVStore) error {
+	return nil // PUBLISH does not modify the store
+}
+
+func (c *PublishCommand) GetCommandInfo() CommandDescription {
+	return CommandDescription{
+		Command:       "PUBLISH",
+		Name:          "Publish",
+		Syntax:        "PUBLISH <channel> <message>",
+		HelpText:      "Send a message to the clients subscribed to a channel and return how many received it",
+		Arity:         3,
+		Flags:         []CommandFlag{FlagFast},
+		ACLCategories: []string{"@pubsub"},
+	}
+}
+
+type SubscribeCommand struct {
+	server *Server
+}
+
+func (c *SubscribeCommand) Execute(conn net.Conn, args []string) string {
+	client := c.server.client(conn)
+	if client == nil {
+		return "ERR SUBSCRIBE is only available to client connections"
+	}
+	counts := c.server.pubsub.subscribe(client, args)
+	lines := make([]string, len(args))
+	for i, channel := range args {
+		lines[i] = fmt.Sprintf("subscribe %s %d", utils.JoinArgs([]string{channel}), counts[i])
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (c *SubscribeCommand) Replay(args []string, store *database.KVStore) error {
+	return nil // SUBSCRIBE does not modify the store
+}
+
+func (c *SubscribeCommand) GetCommandInfo() CommandDescription {
+	return CommandDescription{
+		Command:       "SUBSCRIBE",
+		Name:          "Subscribe",
+		Syntax:        "SUBSCRIBE <channel> [<channel> ...]",
+		HelpText:      "Stream the messages published to the channels to this connection, which accepts no further commands",
+		Arity:         -2,
+		Flags:         []CommandFlag{FlagNoScript},
+		ACLCategories: []string{"@pubsub"},
+	}
+}
@@ -0,0 +1,71 @@
+package protocol
+
+import (
+	"fmt"
+	"net"
+	"sync/atomic"
+
+	"github.com/yashs662/SynchroDB/internal/config"
+	"github.com/yashs662/SynchroDB/internal/metrics"
+	"github.com/yashs662/SynchroDB/internal/ratelimit"
+)
+
+// Values of rate_limit_key.
+const (
+	rateLimitByIP   = "ip"
+	rateLimitByUser = "user"
+)
+
+var rateLimited = metrics.NewCounter("synchrodb_rate_limited_total", "Commands rejected by rate limits")
+
+// rateLimits applies the token bucket limits to commands. Every command takes
+// a token from the commands bucket and write commands also take one from the
+// writes bucket. Buckets are shared by all connections of a client IP, or of
+// a user when rate_limit_key is "user".
+type rateLimits struct {
+	commands *ratelimit.Limiter
+	writes   *ratelimit.Limiter
+	byUser   atomic.Bool
+}
+
+// newRateLimits creates the limits from config. rate_limit_key is applied by
+// Start, which can report it being invalid.
+func newRateLimits(config *config.Config) *rateLimits {
+	return &rateLimits{
+		commands: ratelimit.New(ratelimit.Limit{Rate: float64(config.Server.RateLimit), Burst: config.Server.RateLimitBurst}),
+		writes:   ratelimit.New(ratelimit.Limit{Rate: float64(config.Server.WriteRateLimit), Burst: config.Server.WriteRateLimitBurst}),
+	}
+}
+
+func (r *rateLimits) setKey(key string) error {
+	switch key {
+	case "", rateLimitByIP:
+		r.byUser.Store(false)
+	case rateLimitByUser:
+		r.byUser.Store(true)
+	default:
+		return fmt.Errorf("invalid rate_limit_key %q, expected ip or user", key)
+	}
+	return nil
+}
+
+func (r *rateLimits) key() string {
+	if r.byUser.Load() {
+		return rateLimitByUser
+	}
+	return rateLimitByIP
+}
+
+// allow reports whether the command may run now. Unauthenticated connections
+// are always limited by IP.
+func (r *rateLimits) allow(conn net.Conn, user string, authenticated bool, info CommandDescription) bool {
+	key := "ip:" + clientIP(conn)
+	if authenticated && r.byUser.Load() {
+		key = "user:" + user
+	}
+	if !r.commands.Allow(key) || (info.HasFlag(FlagWrite) && !r.writes.Allow(key)) {
+		rateLimited.Inc()
+		return false
+	}
+	return true
+}
@@ -0,0 +1,275 @@
+package protocol
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yashs662/SynchroDB/internal/config"
+	"github.com/yashs662/SynchroDB/internal/script"
+	"github.com/yashs662/SynchroDB/internal/utils"
+	"github.com/yashs662/SynchroDB/pkg/database"
+)
+
+// defaultScriptTimeLimit applies when script_time_limit_ms is not configured.
+const defaultScriptTimeLimit = config.DefaultScriptTimeLimit * time.Millisecond
+
+// exclusiveCommand is implemented by commands that must not run concurrently
+// with any other command. handleCommand takes the execution lock for writing
+// before running them.
+type exclusiveCommand interface {
+	exclusive()
+}
+
+// scriptCache holds compiled scripts keyed by the SHA1 of their source.
+type scriptCache struct {
+	mu      sync.RWMutex
+	scripts map[string]*script.Program
+}
+
+func newScriptCache() *scriptCache {
+	return &scriptCache{scripts: make(map[string]*script.Program)}
+}
+
+func scriptSHA(source string) string {
+	sum := sha1.Sum([]byte(source))
+	return hex.EncodeToString(sum[:])
+}
+
+// load compiles source, caches it and returns its SHA1.
+func (c *scriptCache) load(source string) (string, *script.Program, error) {
+	sha := scriptSHA(source)
+	if program, ok := c.get(sha); ok {
+		return sha, program, nil
+	}
+	program, err := script.Compile(source)
+	if err != nil {
+		return "", nil, err
+	}
+	c.mu.Lock()
+	c.scripts[sha] = program
+	c.mu.Unlock()
+	return sha, program, nil
+}
+
+func (c *scriptCache) get(sha string) (*script.Program, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	program, ok := c.scripts[strings.ToLower(sha)]
+	return program, ok
+}
+
+func (c *scriptCache) flush() {
+	c.mu.Lock()
+	c.scripts = make(map[string]*script.Program)
+	c.mu.Unlock()
+}
+
+// runScript executes program with the "numkeys key... arg..." arguments shared
+// by EVAL and EVALSHA. It is called with the execution lock held exclusively,
+// so the commands the script issues are applied atomically. Each of those
+// commands persists its own effects to the AOF, so the script text itself is
+// never written and replay does not depend on the script cache.
+func (s *Server) runScript(conn net.Conn, program *script.Program, args []string) string {
+	numKeys, err := strconv.Atoi(args[0])
+	if err != nil || numKeys < 0 {
+		return "ERR number of keys can't be negative or non-integer"
+	}
+	if numKeys > len(args)-1 {
+		return "ERR number of keys can't be greater than number of args"
+	}
+
+	env := &script.Env{
+		Keys:     args[1 : 1+numKeys],
+		Args:     args[1+numKeys:],
+		Deadline: time.Now().Add(s.scriptTimeLimit),
+		Call: func(command []string) (string, error) {
+			return s.callFromScript(conn, command)
+		},
+	}
+
+	value, err := program.Run(env)
+	if err != nil {
+		var callErr *script.CallError
+		switch {
+		case errors.As(err, &callErr):
+			return callErr.Reply
+		case errors.Is(err, script.ErrTimeout):
+			return fmt.Sprintf("ERR script exceeded its execution time limit of %s", s.scriptTimeLimit)
+		}
+		return fmt.Sprintf("ERR script error: %v", err)
+	}
+	return scriptReply(value)
+}
+
+// callFromScript executes a single command on behalf of a script. The
+// execution lock is already held, so the command runs directly instead of
+// going through handleCommand. Audited commands, such as FLUSHDB, are still
+// written to the audit log.
+func (s *Server) callFromScript(conn net.Conn, command []string) (string, error) {
+	cmd, info, exists := s.lookupCommand(strings.ToUpper(command[0]))
+	if !exists {
+		return "", errors.New("ERR unknown command")
+	}
+	if info.HasFlag(FlagNoScript) {
+		return "", fmt.Errorf("ERR command '%s' is not allowed from scripts", info.Command)
+	}
+	user, _ := s.connectionUser(conn)
+	if !info.CheckArity(len(command) - 1) {
+		rejected := fmt.Sprintf("ERR wrong number of arguments for '%s' command", info.Command)
+		s.audit(conn, user, info, command, rejected, true)
+		return "", errors.New(rejected)
+	}
+	if denied := s.checkPermissions(conn, user, info, command[1:]); denied != "" {
+		s.audit(conn, user, info, command, denied, true)
+		return "", errors.New(denied)
+	}
+	reply := cmd.Execute(conn, command[1:])
+	s.audit(conn, user, info, command, reply, false)
+	if strings.HasPrefix(reply, "ERR") {
+		return "", errors.New(reply)
+	}
+	return reply, nil
+}
+
+// scriptReply converts the value returned by a script into a reply. Booleans
+// follow the Redis convention of true as 1 and false as nil, and lists are
+// returned one item per line.
+func scriptReply(value script.Value) string {
+	switch v := value.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		if v {
+			return "1"
+		}
+		return "nil"
+	case float64:
+		return script.FormatNumber(v)
+	case string:
+		return v
+	case *script.List:
+		items := make([]string, len(v.Items))
+		for i, item := range v.Items {
+			items[i] = scriptReply(item)
+		}
+		return utils.FormatMultilineResponse(strings.Join(items, "\n"))
+	}
+	return "nil"
+}
+
+type EvalCommand struct {
+	server *Server
+}
+
+func (c *EvalCommand) exclusive() {}
+
+func (c *EvalCommand) Execute(conn net.Conn, args []string) string {
+	_, program, err := c.server.scripts.load(args[0])
+	if err != nil {
+		return fmt.Sprintf("ERR error compiling script: %v", err)
+	}
+	return c.server.runScript(conn, program, args[1:])
+}
+
+func (c *EvalCommand) Replay(args []string, store *database.KVStore) error {
+	return nil // Scripts persist their effects, not the script itself
+}
+
+func (c *EvalCommand) GetCommandInfo() CommandDescription {
+	return CommandDescription{
+		Command:       "EVAL",
+		Name:          "Evaluate Script",
+		Syntax:        "EVAL <script> <numkeys> [<key> ...] [<arg> ...]",
+		HelpText:      "Run a script atomically, quote the script to include spaces",
+		Arity:         -3,
+		Flags:         []CommandFlag{FlagNoScript, FlagMovableKeys},
+		ACLCategories: []string{"@scripting"},
+	}
+}
+
+type EvalSHACommand struct {
+	server *Server
+}
+
+func (c *EvalSHACommand) exclusive() {}
+
+func (c *EvalSHACommand) Execute(conn net.Conn, args []string) string {
+	program, ok := c.server.scripts.get(args[0])
+	if !ok {
+		return "ERR no matching script, use EVAL or SCRIPT LOAD first"
+	}
+	return c.server.runScript(conn, program, args[1:])
+}
+
+func (c *EvalSHACommand) Replay(args []string, store *database.KVStore) error {
+	return nil // Scripts persist their effects, not the script itself
+}
+
+func (c *EvalSHACommand) GetCommandInfo() CommandDescription {
+	return CommandDescription{
+		Command:       "EVALSHA",
+		Name:          "Evaluate Cached Script",
+		Syntax:        "EVALSHA <sha1> <numkeys> [<key> ...] [<arg> ...]",
+		HelpText:      "Run a script previously loaded with SCRIPT LOAD or EVAL by its SHA1",
+		Arity:         -3,
+		Flags:         []CommandFlag{FlagNoScript, FlagMovableKeys},
+		ACLCategories: []string{"@scripting"},
+	}
+}
+
+type ScriptCommand struct {
+	server *Server
+}
+
+func (c *ScriptCommand) Execute(conn net.Conn, args []string) string {
+	switch strings.ToUpper(args[0]) {
+	case "LOAD":
+		if len(args) != 2 {
+			return "ERR wrong number of arguments for 'SCRIPT LOAD' command"
+		}
+		sha, _, err := c.server.scripts.load(args[1])
+		if err != nil {
+			return fmt.Sprintf("ERR error compiling script: %v", err)
+		}
+		return sha
+	case "EXISTS":
+		if len(args) < 2 {
+			return "ERR wrong number of arguments for 'SCRIPT EXISTS' command"
+		}
+		results := make([]string, len(args)-1)
+		for i, sha := range args[1:] {
+			results[i] = "0"
+			if _, ok := c.server.scripts.get(sha); ok {
+				results[i] = "1"
+			}
+		}
+		return utils.FormatMultilineResponse(strings.Join(results, "\n"))
+	case "FLUSH":
+		c.server.scripts.flush()
+		return "OK"
+	}
+	return fmt.Sprintf("ERR unknown subcommand '%s' for 'SCRIPT' command", args[0])
+}
+
+func (c *ScriptCommand) Replay(args []string, store *database.KVStore) error {
+	return nil // No-op for replay
+}
+
+func (c *ScriptCommand) GetCommandInfo() CommandDescription {
+	return CommandDescription{
+		Command:       "SCRIPT",
+		Name:          "Script Cache",
+		Syntax:        "SCRIPT LOAD <script> | SCRIPT EXISTS <sha1> [<sha1> ...] | SCRIPT FLUSH",
+		HelpText:      "Load scripts into the cache, check whether they are cached or empty the cache",
+		Arity:         -2,
+		Flags:         []CommandFlag{FlagNoScript},
+		ACLCategories: []string{"@scripting"},
+	}
+}
@@ -6,46 +6,99 @@ import (
 	"crypto/tls"
 	"fmt"
 	"net"
+	"net/http"
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/yashs662/SynchroDB/internal/audit"
 	"github.com/yashs662/SynchroDB/internal/config"
+	"github.com/yashs662/SynchroDB/internal/latency"
 	"github.com/yashs662/SynchroDB/internal/logger"
+	"github.com/yashs662/SynchroDB/internal/utils"
 	"github.com/yashs662/SynchroDB/pkg/database"
 )
 
+// benchmarkKeyPrefix marks keys written by the benchmark client. Writes to
+// these keys are never persisted to the AOF file.
+const benchmarkKeyPrefix = "synchrodb-benchmark:"
+
 type Server struct {
-	listener             net.Listener
-	conns                sync.Map
-	authenticatedClients map[net.Conn]bool
-	authMutex            sync.RWMutex
-	authEnabled          bool
-	dbPassword           string
-	store                *database.KVStore
-	aofWriter            *database.AOFWriter
-	persistenceEnabled   bool
-	commandRegistry      *database.CommandRegistry
-	connCount            int
-	connMutex            sync.Mutex
-	maxConnections       int
-	rateLimit            int
-	shutdownChan         chan struct{}
+	listeners          []net.Listener
+	listenerMutex      sync.Mutex
+	metricsServer      *http.Server
+	httpServer         *http.Server
+	gatewayStreams     atomic.Int32
+	conns              sync.Map // net.Conn to *client
+	nextClientID       atomic.Int64
+	pause              *clientPause
+	authEnabled        atomic.Bool
+	acl                *acl
+	aclFile            string
+	clientCertUser     bool
+	certificates       atomic.Pointer[certReloader]
+	authGuard          *authGuard
+	store              *database.KVStore
+	aofWriter          *database.AOFWriter
+	auditLog           *audit.Log
+	persistenceEnabled bool
+	commandRegistry    *database.CommandRegistry
+	connCount          int
+	connMutex          sync.Mutex
+	maxConnections     atomic.Int64
+	rateLimits         *rateLimits
+	slowlog            *slowLog
+	monitors           *monitors
+	pubsub             *pubsub
+	startTime          time.Time
+	// configPath is the file CONFIG REWRITE and ReloadConfig use, config
+	// its content when last read, and configChanged the settings changed by
+	// CONFIG SET since
+	configPath    string
+	configMu      sync.Mutex
+	config        *config.Config
+	configChanged map[string]bool
+	shutdownChan  chan struct{}
+	// execMutex is held for reading while a command executes and for writing
+	// while a script runs, so scripts never interleave with other commands.
+	execMutex       sync.RWMutex
+	scripts         *scriptCache
+	scriptTimeLimit time.Duration
+	customCommands  []Command
+	started         atomic.Bool
 }
 
 func NewServer(config *config.Config, store *database.KVStore, aofWriter *database.AOFWriter) *Server {
 	server := &Server{
-		authEnabled:          config.Server.AuthEnabled,
-		dbPassword:           config.Server.Password,
-		store:                store,
-		aofWriter:            aofWriter,
-		authenticatedClients: make(map[net.Conn]bool),
-		commandRegistry:      database.NewCommandRegistry(),
-		maxConnections:       config.Server.MaxConnections,
-		rateLimit:            config.Server.RateLimit,
-		shutdownChan:         make(chan struct{}),
+		aclFile:         config.Server.ACLFile,
+		clientCertUser:  config.Server.ClientCertUser,
+		store:           store,
+		aofWriter:       aofWriter,
+		pause:           newClientPause(),
+		commandRegistry: database.NewCommandRegistry(),
+		rateLimits:      newRateLimits(config),
+		slowlog:         newSlowLog(config),
+		monitors:        newMonitors(),
+		pubsub:          newPubsub(),
+		shutdownChan:    make(chan struct{}),
+		startTime:       time.Now(),
+		configPath:      config.Path,
+		config:          config,
+		configChanged:   make(map[string]bool),
+		scripts:         newScriptCache(),
+		scriptTimeLimit: time.Duration(config.Server.ScriptTimeLimit) * time.Millisecond,
+	}
+	if server.scriptTimeLimit <= 0 {
+		server.scriptTimeLimit = defaultScriptTimeLimit
 	}
+	server.authGuard = newAuthGuard(config)
+	server.acl = newACL(config.Server.Password, server.isCommand, server.isCategory)
+	server.authEnabled.Store(config.Server.AuthEnabled)
+	server.maxConnections.Store(int64(config.Server.MaxConnections))
+	server.registerGauges()
+	latency.SetThreshold(time.Duration(config.Server.LatencyMonitorThreshold) * time.Millisecond)
 
 	// Register commands
 	server.registerCommands()
@@ -62,8 +115,26 @@ func (s *Server) registerCommands() {
 }
 
 func (s *Server) Start(config *config.Config) error {
-	s.authEnabled = config.Server.AuthEnabled
-	s.dbPassword = config.Server.Password
+	s.started.Store(true)
+	s.authEnabled.Store(config.Server.AuthEnabled)
+	if err := s.rateLimits.setKey(config.Server.RateLimitKey); err != nil {
+		return err
+	}
+	if s.aclFile != "" {
+		if err := s.acl.load(s.aclFile); err != nil {
+			return fmt.Errorf("failed to load ACL users: %w", err)
+		}
+		logger.Infof("Loaded ACL users from %s", s.aclFile)
+	}
+	if config.Audit.File != "" {
+		var err error
+		s.auditLog, err = audit.Open(config.Audit.File)
+		if err != nil {
+			return err
+		}
+		defer s.auditLog.Close()
+		logger.Infof("Writing the audit log to %s", config.Audit.File)
+	}
 	aofFilePath := config.Server.PersistentAOFPath
 	if aofFilePath != "" {
 		var err error
@@ -88,54 +159,68 @@ func (s *Server) Start(config *config.Config) error {
 		logger.Warn("Persistence is disabled because the file path is empty in the config")
 	}
 
-	cert, err := tls.LoadX509KeyPair(config.Server.CertFile, config.Server.KeyFile)
-	if err != nil {
-		return fmt.Errorf("failed to load TLS certificates: %w", err)
+	tlsConfig := func() (*tls.Config, error) {
+		if certificates := s.certificates.Load(); certificates != nil {
+			return serverTLSConfig(config, certificates)
+		}
+		certificates, err := newCertReloader(config.Server.CertFile, config.Server.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		s.certificates.Store(certificates)
+		go certificates.watch(certPollInterval, s.shutdownChan)
+		return serverTLSConfig(config, certificates)
 	}
-
-	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}}
-	s.listener, err = tls.Listen("tcp", config.Server.Address, tlsConfig)
+	listeners, err := s.openListeners(config, tlsConfig)
 	if err != nil {
-		return fmt.Errorf("failed to start TLS listener: %w", err)
+		return err
 	}
-	defer s.listener.Close()
-
-	logger.Infof("Secure server is listening on %s", config.Server.Address)
-
-	for {
-		conn, err := s.listener.Accept()
-		select {
-		case <-s.shutdownChan:
-			return nil
-		default:
-		}
-		if err != nil {
-			logger.Warnf("Failed to accept connection: %v", err)
-			continue
+	s.listenerMutex.Lock()
+	s.listeners = listeners
+	s.listenerMutex.Unlock()
+	if config.Server.MetricsAddress != "" {
+		if err := s.serveMetrics(config.Server.MetricsAddress); err != nil {
+			for _, listener := range listeners {
+				listener.Close()
+			}
+			return err
 		}
-
-		s.connMutex.Lock()
-		if s.maxConnections > 0 && s.connCount >= s.maxConnections {
-			s.connMutex.Unlock()
-			logger.Warnf("Connection limit reached, rejecting connection from %s", conn.RemoteAddr().String())
-			conn.Close()
-			continue
+	}
+	if len(config.Server.HTTPListeners) > 0 {
+		if err := s.serveGateway(config.Server.HTTPListeners, tlsConfig); err != nil {
+			for _, listener := range listeners {
+				listener.Close()
+			}
+			return err
 		}
-		s.connCount++
-		s.connMutex.Unlock()
-
-		logger.Debugf("Accepted connection from %s", conn.RemoteAddr().String())
+	}
 
-		s.conns.Store(conn, struct{}{})
-		go s.handleConnection(conn)
+	var wg sync.WaitGroup
+	for _, listener := range listeners {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer listener.Close()
+			s.serve(listener)
+		}()
 	}
+	wg.Wait()
+	return nil
 }
 
 func (s *Server) Shutdown(ctx context.Context) error {
 	close(s.shutdownChan)
-	if s.listener != nil {
-		s.listener.Close()
+	s.listenerMutex.Lock()
+	for _, listener := range s.listeners {
+		listener.Close()
+	}
+	if s.metricsServer != nil {
+		s.metricsServer.Close()
+	}
+	if s.httpServer != nil {
+		s.httpServer.Close()
 	}
+	s.listenerMutex.Unlock()
 
 	var wg sync.WaitGroup
 	s.conns.Range(func(key, value interface{}) bool {
@@ -162,80 +247,194 @@ func (s *Server) Shutdown(ctx context.Context) error {
 	}
 }
 
-func (s *Server) handleConnection(conn net.Conn) {
+func (s *Server) handleConnection(client *client) {
+	conn := client.conn
 	defer func() {
 		conn.Close()
 		s.conns.Delete(conn)
-		s.connMutex.Lock()
-		s.connCount--
-		s.connMutex.Unlock()
+		s.authGuard.forget(conn)
+		s.releaseConnection()
 	}()
-	clientAddr := conn.RemoteAddr().String()
-	logger.Debugf("Handling connection from %s", clientAddr)
+	client.log.Debug("Handling connection")
 
 	tlsConn, ok := conn.(*tls.Conn)
 	if ok {
 		if err := tlsConn.Handshake(); err != nil {
-			logger.Errorf("TLS handshake failed with %s: %v", clientAddr, err)
+			client.log.Error("TLS handshake failed", logger.Err(err))
 			return
 		}
+		s.authenticateClientCert(conn, tlsConn.ConnectionState())
 	}
 
 	reader := bufio.NewReader(conn)
-	var rateLimiter <-chan time.Time
-	if s.rateLimit > 0 {
-		rateLimiter = time.Tick(time.Second / time.Duration(s.rateLimit))
-	}
-
 	for {
-		if s.rateLimit > 0 {
-			<-rateLimiter
-		}
 		command, err := reader.ReadString('\n')
 		if err != nil {
-			logger.Debugf("Connection closed by %s: %v", clientAddr, err)
+			client.log.Debug("Connection closed by the client", logger.Err(err))
 			return
 		}
+		client.received(len(command))
+		netInputBytes.Add(int64(len(command)))
 
 		command = strings.TrimSpace(command)
 		response := s.handleCommand(conn, command)
-		conn.Write([]byte(response + "\n"))
+		// values may contain newlines, which would break the line based protocol
+		client.write(utils.FormatMultilineResponse(response) + "\n")
+		if s.authGuard.exhausted(conn) {
+			client.log.Warn("Closing connection after too many failed authentication attempts")
+			return
+		}
+		if client.closeAfterReply.Load() {
+			client.log.Debug("Closing connection killed by CLIENT KILL")
+			return
+		}
+		if client.monitoring.Load() {
+			s.streamMonitor(client, reader)
+			return
+		}
+		if sub := s.pubsub.subscription(client); sub != nil {
+			s.streamMessages(client, sub, reader)
+			return
+		}
 	}
 }
 
 func (s *Server) handleCommand(conn net.Conn, command string) string {
-	// Enforce authentication
-	if s.authEnabled {
-		s.authMutex.RLock()
-		authenticated := s.authenticatedClients[conn]
-		s.authMutex.RUnlock()
-
-		authCommand := AuthCommand{}
-		authCommandName := authCommand.GetCommandInfo().Command
-		// add a space after the command name to avoid partial matches
-		// e.g. AUTH and AUTHENTICATE
-		authCommandName += " "
-
-		if !authenticated && !strings.HasPrefix(strings.ToUpper(command), authCommandName) {
-			return "ERR authentication required"
-		}
+	parts, err := utils.SplitArgs(command)
+	if err != nil {
+		return fmt.Sprintf("ERR %v", err)
 	}
-
-	parts := strings.Fields(command)
 	if len(parts) == 0 {
 		return "ERR invalid command"
 	}
+	return s.runCommand(conn, parts)
+}
+
+// runCommand runs the command parts, its name followed by its arguments, for
+// conn: it checks authentication, arity, permissions and rate limits, then
+// executes it, recording it in the stats, the slow log and the audit log.
+func (s *Server) runCommand(conn net.Conn, parts []string) string {
+	cmd, info, exists := s.lookupCommand(strings.ToUpper(parts[0]))
+	client := s.client(conn)
+	if client != nil {
+		client.requests.Add(1)
+	}
+
+	// Enforce authentication, only commands flagged no_auth run before it
+	user, authenticated := s.connectionUser(conn)
+	if !authenticated && !info.HasFlag(FlagNoAuth) {
+		s.audit(conn, user, info, parts, "ERR authentication required", true)
+		return "ERR authentication required"
+	}
 
-	cmd, exists := s.commandRegistry.Get(strings.ToUpper(parts[0]))
 	if !exists {
 		return "ERR unknown command"
 	}
+	name := strings.ToLower(info.Command)
+	if client != nil {
+		client.setLastCommand(name)
+	}
+	stat := commandStatFor(name)
+	if rejected := s.admit(conn, user, authenticated, info, parts[1:]); rejected != "" {
+		stat.rejected.Inc()
+		s.audit(conn, user, info, parts, rejected, true)
+		return rejected
+	}
+	if s.monitors.active() {
+		s.monitors.feed(monitorLine(conn, info, parts))
+	}
+	_, exclusive := cmd.(exclusiveCommand)
+	// Admin commands run during a pause, so that CLIENT UNPAUSE can end it
+	if !info.HasFlag(FlagAdmin) {
+		s.pause.wait(exclusive || info.HasFlag(FlagWrite), s.shutdownChan)
+	}
+
+	if exclusive {
+		s.execMutex.Lock()
+		defer s.execMutex.Unlock()
+	} else {
+		s.execMutex.RLock()
+		defer s.execMutex.RUnlock()
+	}
+	start := time.Now()
+	response := cmd.Execute(conn, parts[1:])
+	duration := time.Since(start)
+	stat.record(duration, strings.HasPrefix(response, "ERR"))
+	s.slowlog.record(conn, client.getName(), info, parts, duration)
+	s.audit(conn, user, info, parts, response, false)
+	if info.HasFlag(FlagFast) {
+		latency.Record(latency.EventFastCommand, duration)
+	} else {
+		latency.Record(latency.EventCommand, duration)
+	}
+	return response
+}
+
+// admit checks whether user may run the command described by info with args
+// now, returning the error reply when not.
+func (s *Server) admit(conn net.Conn, user string, authenticated bool, info CommandDescription, args []string) string {
+	if !info.CheckArity(len(args)) {
+		return fmt.Sprintf("ERR wrong number of arguments for '%s' command", info.Command)
+	}
+	if !info.HasFlag(FlagNoAuth) {
+		if denied := s.checkPermissions(conn, user, info, args); denied != "" {
+			return denied
+		}
+	}
+	if !s.rateLimits.allow(conn, user, authenticated, info) {
+		return "ERR rate limited"
+	}
+	return ""
+}
 
-	return cmd.Execute(conn, parts[1:])
+// appendToAOF persists args as a single AOF entry when persistence is enabled.
+// The key positions of the entry's command decide whether it only touches
+// benchmark keys, in which case it is skipped.
+func (s *Server) appendToAOF(args ...string) {
+	if !s.persistenceEnabled {
+		return
+	}
+	if _, info, exists := s.lookupCommand(args[0]); exists {
+		if keys := info.Keys(args[1:]); len(keys) > 0 && allBenchmarkKeys(keys) {
+			return
+		}
+	}
+	if err := s.aofWriter.Write(utils.JoinArgs(args)); err != nil {
+		logger.Errorf("Failed to write to AOF: %v", err)
+	}
 }
 
-func (s *Server) authenticateClient(conn net.Conn) {
-	s.authMutex.Lock()
-	s.authenticatedClients[conn] = true
-	s.authMutex.Unlock()
+func allBenchmarkKeys(keys []string) bool {
+	for _, key := range keys {
+		if !strings.HasPrefix(key, benchmarkKeyPrefix) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Server) authenticateClient(conn net.Conn, user string) {
+	if client := s.client(conn); client != nil {
+		client.mu.Lock()
+		client.user = user
+		client.authenticated = true
+		client.mu.Unlock()
+	}
+}
+
+// connectionUser returns the ACL user conn runs as. Connections that have not
+// authenticated run as the default user when authentication is disabled.
+func (s *Server) connectionUser(conn net.Conn) (string, bool) {
+	if client := s.client(conn); client != nil {
+		client.mu.Lock()
+		user, authenticated := client.user, client.authenticated
+		client.mu.Unlock()
+		if authenticated {
+			return user, true
+		}
+	}
+	if !s.authEnabled.Load() {
+		return defaultUser, true
+	}
+	return "", false
 }
@@ -0,0 +1,168 @@
+package protocol
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/yashs662/SynchroDB/internal/config"
+	"github.com/yashs662/SynchroDB/internal/utils"
+)
+
+// Limits of the slow log entries. Like Redis, they keep at most
+// slowlogMaxArgs arguments of at most slowlogMaxArgLen bytes each.
+const (
+	slowlogMaxArgs   = 32
+	slowlogMaxArgLen = 128
+)
+
+// redacted replaces secrets in the arguments shown by SLOWLOG and MONITOR.
+const redacted = "(redacted)"
+
+// slowlogEntry is a command that ran for longer than the threshold.
+type slowlogEntry struct {
+	id       int64
+	time     time.Time
+	duration time.Duration
+	args     []string
+	addr     string
+	name     string
+}
+
+func (e slowlogEntry) String() string {
+	return fmt.Sprintf("id=%d time=%d duration_us=%d addr=%s name=%s args=%s",
+		e.id, e.time.Unix(), e.duration.Microseconds(), e.addr, e.name, utils.JoinArgs(e.args))
+}
+
+// slowLog keeps the most recent slow commands, newest first. A negative
+// threshold disables it and zero logs every command.
+type slowLog struct {
+	threshold atomic.Int64 // time.Duration
+	maxLen    atomic.Int64
+
+	mu      sync.Mutex
+	entries []slowlogEntry
+	nextID  int64
+}
+
+func newSlowLog(config *config.Config) *slowLog {
+	l := &slowLog{}
+	l.threshold.Store(int64(time.Duration(config.Server.SlowlogThreshold) * time.Microsecond))
+	l.maxLen.Store(int64(config.Server.SlowlogMaxLen))
+	return l
+}
+
+// record logs the command args when it ran for longer than the threshold.
+func (l *slowLog) record(conn net.Conn, name string, info CommandDescription, args []string, duration time.Duration) {
+	threshold := time.Duration(l.threshold.Load())
+	if threshold < 0 || duration < threshold {
+		return
+	}
+	entry := slowlogEntry{
+		time:     time.Now(),
+		duration: duration,
+		args:     truncateArgs(redactArgs(info, args)),
+		addr:     conn.RemoteAddr().String(),
+		name:     name,
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry.id = l.nextID
+	l.nextID++
+	l.entries = append([]slowlogEntry{entry}, l.entries...)
+	l.trim()
+}
+
+// trim drops the oldest entries beyond maxLen. Callers hold mu.
+func (l *slowLog) trim() {
+	if maxLen := int(l.maxLen.Load()); len(l.entries) > maxLen {
+		l.entries = l.entries[:maxLen]
+	}
+}
+
+func (l *slowLog) setMaxLen(maxLen int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.maxLen.Store(int64(maxLen))
+	l.trim()
+}
+
+// get returns up to count entries, newest first, or all of them when count
+// is negative.
+func (l *slowLog) get(count int) []slowlogEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if count < 0 || count > len(l.entries) {
+		count = len(l.entries)
+	}
+	return append([]slowlogEntry(nil), l.entries[:count]...)
+}
+
+func (l *slowLog) len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.entries)
+}
+
+func (l *slowLog) reset() {
+	l.mu.Lock()
+	l.entries = nil
+	l.mu.Unlock()
+}
+
+// redactArgs returns args, the command name first, with passwords replaced:
+// every argument of AUTH, the password rules of ACL SETUSER and the password
+// set by CONFIG SET.
+func redactArgs(info CommandDescription, args []string) []string {
+	switch info.Command {
+	case "AUTH":
+		redactedArgs := []string{args[0]}
+		for range args[1:] {
+			redactedArgs = append(redactedArgs, redacted)
+		}
+		return redactedArgs
+	case "ACL":
+		if len(args) < 3 || !strings.EqualFold(args[1], "SETUSER") {
+			return args
+		}
+		redactedArgs := append([]string(nil), args...)
+		for i, rule := range redactedArgs[3:] {
+			if rule != "" && strings.ContainsRune("><#!", rune(rule[0])) {
+				redactedArgs[i+3] = rule[:1] + redacted
+			}
+		}
+		return redactedArgs
+	case "CONFIG":
+		if len(args) < 4 || !strings.EqualFold(args[1], "SET") {
+			return args
+		}
+		redactedArgs := append([]string(nil), args...)
+		for i := 2; i+1 < len(redactedArgs); i += 2 {
+			if strings.EqualFold(redactedArgs[i], "password") {
+				redactedArgs[i+1] = redacted
+			}
+		}
+		return redactedArgs
+	}
+	return args
+}
+
+// truncateArgs shortens args to what the slow log keeps.
+func truncateArgs(args []string) []string {
+	truncated := make([]string, 0, min(len(args), slowlogMaxArgs))
+	for i, arg := range args {
+		if i == slowlogMaxArgs-1 && len(args) > slowlogMaxArgs {
+			truncated = append(truncated, fmt.Sprintf("... (%d more arguments)", len(args)-i))
+			break
+		}
+		if len(arg) > slowlogMaxArgLen {
+			arg = fmt.Sprintf("%s... (%d more bytes)", arg[:slowlogMaxArgLen], len(arg)-slowlogMaxArgLen)
+		}
+		truncated = append(truncated, arg)
+	}
+	return truncated
+}
@@ -0,0 +1,62 @@
+package protocol
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/yashs662/SynchroDB/internal/utils"
+	"github.com/yashs662/SynchroDB/pkg/database"
+)
+
+// defaultSlowlogGetCount is how many entries SLOWLOG GET returns without a
+// count.
+const defaultSlowlogGetCount = 10
+
+type SlowlogCommand struct {
+	server *Server
+}
+
+func (c *SlowlogCommand) Execute(conn net.Conn, args []string) string {
+	switch strings.ToUpper(args[0]) {
+	case "GET":
+		if len(args) > 2 {
+			return "ERR wrong number of arguments for 'SLOWLOG GET' command"
+		}
+		count := defaultSlowlogGetCount
+		if len(args) == 2 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < -1 {
+				return "ERR count should be greater than or equal to -1"
+			}
+			count = n
+		}
+		var lines []string
+		for _, entry := range c.server.slowlog.get(count) {
+			lines = append(lines, entry.String())
+		}
+		return utils.FormatMultilineResponse(strings.Join(lines, "\n"))
+	case "LEN":
+		return strconv.Itoa(c.server.slowlog.len())
+	case "RESET":
+		c.server.slowlog.reset()
+		return "OK"
+	}
+	return fmt.Sprintf("ERR unknown subcommand '%s' for 'SLOWLOG' command", args[0])
+}
+
+func (c *SlowlogCommand) Replay(args []string, store *database.KVStore) error {
+	return nil // The slow log is not persisted in the AOF
+}
+
+func (c *SlowlogCommand) GetCommandInfo() CommandDescription {
+	return CommandDescription{
+		Command:  "SLOWLOG",
+		Name:     "Slow Log",
+		Syntax:   "SLOWLOG GET [<count>] | SLOWLOG LEN | SLOWLOG RESET",
+		HelpText: "Show the most recent commands that ran for longer than slowlog_threshold_us, newest first",
+		Arity:    -2,
+		Flags:    []CommandFlag{FlagAdmin, FlagNoScript},
+	}
+}
@@ -0,0 +1,64 @@
+package protocol
+
+import (
+	"time"
+
+	"github.com/yashs662/SynchroDB/internal/metrics"
+)
+
+var (
+	connectionsReceived = metrics.NewCounter("synchrodb_connections_received_total", "Connections accepted by the listeners")
+	connectionsRejected = metrics.NewCounter("synchrodb_rejected_connections_total", "Connections refused because of max_connections")
+	netInputBytes       = metrics.NewCounter("synchrodb_net_input_bytes_total", "Bytes read from clients")
+	netOutputBytes      = metrics.NewCounter("synchrodb_net_output_bytes_total", "Bytes written to clients")
+
+	commandCalls     = metrics.NewCounterVec("synchrodb_commands_total", "Commands run, by command", "command")
+	commandsRejected = metrics.NewCounterVec("synchrodb_commands_rejected_total", "Commands refused before running because of their arity, the ACL or a rate limit, by command", "command")
+	commandsFailed   = metrics.NewCounterVec("synchrodb_commands_failed_total", "Commands that ran and replied with an error, by command", "command")
+	commandDuration  = metrics.NewHistogramVec("synchrodb_command_duration_seconds", "Time spent running commands, by command", "command", metrics.LatencyBuckets)
+)
+
+// commandStat holds the metrics of one command, as reported by INFO
+// commandstats and the metrics endpoint.
+type commandStat struct {
+	calls    *metrics.Counter
+	rejected *metrics.Counter
+	failed   *metrics.Counter
+	duration *metrics.Histogram
+}
+
+// commandStatFor returns the metrics of command, by lowercase command name.
+func commandStatFor(command string) commandStat {
+	return commandStat{
+		calls:    commandCalls.With(command),
+		rejected: commandsRejected.With(command),
+		failed:   commandsFailed.With(command),
+		duration: commandDuration.With(command),
+	}
+}
+
+func (c commandStat) record(duration time.Duration, failed bool) {
+	c.calls.Inc()
+	c.duration.ObserveDuration(duration)
+	if failed {
+		c.failed.Inc()
+	}
+}
+
+// eachCommandStat calls fn for every command looked up so far, sorted by
+// name. commandStatFor creates all the metrics of a command at once, so the
+// calls cover every command.
+func eachCommandStat(fn func(command string, stat commandStat)) {
+	commandCalls.Each(func(command string, _ *metrics.Counter) {
+		fn(command, commandStatFor(command))
+	})
+}
+
+// commandTotals returns the calls and rejected calls of all commands.
+func commandTotals() (calls, rejected int64) {
+	eachCommandStat(func(command string, stat commandStat) {
+		calls += stat.calls.Value()
+		rejected += stat.rejected.Value()
+	})
+	return calls, rejected
+}
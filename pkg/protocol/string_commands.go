@@ -0,0 +1,526 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yashs662/SynchroDB/internal/utils"
+	"github.com/yashs662/SynchroDB/pkg/database"
+)
+
+var (
+	errSyntax     = errors.New("syntax error")
+	errInvalidTTL = errors.New("invalid TTL")
+)
+
+// parseExpiration converts an EX, PX, EXAT or PXAT option and its argument
+// into an absolute expiration time.
+func parseExpiration(option, value string) (time.Time, error) {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n <= 0 {
+		return time.Time{}, errInvalidTTL
+	}
+
+	switch strings.ToUpper(option) {
+	case "EX":
+		if n > math.MaxInt64/int64(time.Second) {
+			return time.Time{}, errInvalidTTL
+		}
+		return time.Now().Add(time.Duration(n) * time.Second), nil
+	case "PX":
+		if n > math.MaxInt64/int64(time.Millisecond) {
+			return time.Time{}, errInvalidTTL
+		}
+		return time.Now().Add(time.Duration(n) * time.Millisecond), nil
+	case "EXAT":
+		return time.Unix(n, 0), nil
+	case "PXAT":
+		return time.UnixMilli(n), nil
+	}
+	return time.Time{}, errSyntax
+}
+
+// parseSetOptions parses the optional arguments of SET. The returned bool
+// reports whether the GET option was given.
+func parseSetOptions(args []string) (database.SetOptions, bool, error) {
+	var opts database.SetOptions
+	get := false
+	hasExpiration := false
+
+	for i := 0; i < len(args); i++ {
+		switch option := strings.ToUpper(args[i]); option {
+		case "NX":
+			if opts.XX {
+				return opts, false, errSyntax
+			}
+			opts.NX = true
+		case "XX":
+			if opts.NX {
+				return opts, false, errSyntax
+			}
+			opts.XX = true
+		case "GET":
+			get = true
+		case "KEEPTTL":
+			if hasExpiration {
+				return opts, false, errSyntax
+			}
+			opts.KeepTTL = true
+			hasExpiration = true
+		case "EX", "PX", "EXAT", "PXAT":
+			if hasExpiration || i+1 >= len(args) {
+				return opts, false, errSyntax
+			}
+			expireAt, err := parseExpiration(option, args[i+1])
+			if err != nil {
+				return opts, false, err
+			}
+			opts.ExpireAt = expireAt
+			hasExpiration = true
+			i++
+		default:
+			return opts, false, errSyntax
+		}
+	}
+	return opts, get, nil
+}
+
+// setAOFArgs builds the AOF entry for a SET that has been applied. Expirations
+// are always stored as absolute timestamps so that replaying the file later
+// restores the original deadline.
+func setAOFArgs(key, value string, opts database.SetOptions) []string {
+	args := []string{"SET", key, value}
+	if !opts.ExpireAt.IsZero() {
+		args = append(args, "PXAT", strconv.FormatInt(opts.ExpireAt.UnixMilli(), 10))
+	} else if opts.KeepTTL {
+		args = append(args, "KEEPTTL")
+	}
+	return args
+}
+
+// normalizeRange converts the possibly negative start and end offsets used by
+// GETRANGE into a valid slice range for a string of the given length.
+func normalizeRange(start, end, length int) (int, int, bool) {
+	if start < 0 {
+		start += length
+	}
+	if end < 0 {
+		end += length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end >= length {
+		end = length - 1
+	}
+	if length == 0 || start > end {
+		return 0, 0, false
+	}
+	return start, end + 1, true
+}
+
+type MSetCommand struct {
+	server *Server
+}
+
+func (c *MSetCommand) Execute(conn net.Conn, args []string) string {
+	if len(args) == 0 || len(args)%2 != 0 {
+		return "ERR wrong number of arguments for 'MSET' command"
+	}
+	pairs := make(map[string]string, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		pairs[args[i]] = args[i+1]
+	}
+	c.server.store.MSet(pairs)
+	c.server.appendToAOF(append([]string{"MSET"}, args...)...)
+	return "OK"
+}
+
+func (c *MSetCommand) Replay(args []string, store *database.KVStore) error {
+	if len(args) == 0 || len(args)%2 != 0 {
+		return fmt.Errorf("invalid arguments for 'MSET' command")
+	}
+	pairs := make(map[string]string, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		pairs[args[i]] = args[i+1]
+	}
+	store.MSet(pairs)
+	return nil
+}
+
+func (c *MSetCommand) GetCommandInfo() CommandDescription {
+	return CommandDescription{
+		Command:       "MSET",
+		Name:          "Multi Set",
+		Syntax:        "MSET <key> <value> [<key> <value> ...]",
+		HelpText:      "Atomically set multiple keys to multiple values",
+		Arity:         -3,
+		Flags:         []CommandFlag{FlagWrite},
+		FirstKey:      1,
+		LastKey:       -1,
+		KeyStep:       2,
+		ACLCategories: []string{"@string"},
+	}
+}
+
+type MGetCommand struct {
+	server *Server
+}
+
+func (c *MGetCommand) Execute(conn net.Conn, args []string) string {
+	values, found := c.server.store.MGet(args)
+	for i := range values {
+		if !found[i] {
+			values[i] = "nil"
+		}
+	}
+	return utils.FormatMultilineResponse(strings.Join(values, "\n"))
+}
+
+func (c *MGetCommand) Replay(args []string, store *database.KVStore) error {
+	return nil // No-op for replay
+}
+
+func (c *MGetCommand) GetCommandInfo() CommandDescription {
+	return CommandDescription{
+		Command:       "MGET",
+		Name:          "Multi Get",
+		Syntax:        "MGET <key> [<key> ...]",
+		HelpText:      "Get the values of all the given keys, one per line",
+		Arity:         -2,
+		Flags:         []CommandFlag{FlagReadOnly, FlagFast},
+		FirstKey:      1,
+		LastKey:       -1,
+		KeyStep:       1,
+		ACLCategories: []string{"@string"},
+	}
+}
+
+type SetNXCommand struct {
+	server *Server
+}
+
+func (c *SetNXCommand) Execute(conn net.Conn, args []string) string {
+	key, value := args[0], args[1]
+	if _, _, applied := c.server.store.SetWithOptions(key, value, database.SetOptions{NX: true}); !applied {
+		return "0"
+	}
+	c.server.appendToAOF("SET", key, value)
+	return "1"
+}
+
+func (c *SetNXCommand) Replay(args []string, store *database.KVStore) error {
+	if len(args) != 2 {
+		return fmt.Errorf("invalid arguments for 'SETNX' command")
+	}
+	store.SetWithOptions(args[0], args[1], database.SetOptions{NX: true})
+	return nil
+}
+
+func (c *SetNXCommand) GetCommandInfo() CommandDescription {
+	return CommandDescription{
+		Command:       "SETNX",
+		Name:          "Set If Not Exists",
+		Syntax:        "SETNX <key> <value>",
+		HelpText:      "Set a key only if it does not already exist, returning 1 if it was set",
+		Arity:         3,
+		Flags:         []CommandFlag{FlagWrite, FlagFast},
+		FirstKey:      1,
+		LastKey:       1,
+		KeyStep:       1,
+		ACLCategories: []string{"@string"},
+	}
+}
+
+type GetSetCommand struct {
+	server *Server
+}
+
+func (c *GetSetCommand) Execute(conn net.Conn, args []string) string {
+	key, value := args[0], args[1]
+	old, existed, _ := c.server.store.SetWithOptions(key, value, database.SetOptions{})
+	c.server.appendToAOF("SET", key, value)
+	if !existed {
+		return "nil"
+	}
+	return old
+}
+
+func (c *GetSetCommand) Replay(args []string, store *database.KVStore) error {
+	if len(args) != 2 {
+		return fmt.Errorf("invalid arguments for 'GETSET' command")
+	}
+	store.Set(args[0], args[1])
+	return nil
+}
+
+func (c *GetSetCommand) GetCommandInfo() CommandDescription {
+	return CommandDescription{
+		Command:       "GETSET",
+		Name:          "Get and Set",
+		Syntax:        "GETSET <key> <value>",
+		HelpText:      "Set a key to a value and return its old value",
+		Arity:         3,
+		Flags:         []CommandFlag{FlagWrite, FlagFast},
+		FirstKey:      1,
+		LastKey:       1,
+		KeyStep:       1,
+		ACLCategories: []string{"@string"},
+	}
+}
+
+type AppendCommand struct {
+	server *Server
+}
+
+func (c *AppendCommand) Execute(conn net.Conn, args []string) string {
+	key, value := args[0], args[1]
+	length := c.server.store.Append(key, value)
+	c.server.appendToAOF("APPEND", key, value)
+	return strconv.Itoa(length)
+}
+
+func (c *AppendCommand) Replay(args []string, store *database.KVStore) error {
+	if len(args) != 2 {
+		return fmt.Errorf("invalid arguments for 'APPEND' command")
+	}
+	store.Append(args[0], args[1])
+	return nil
+}
+
+func (c *AppendCommand) GetCommandInfo() CommandDescription {
+	return CommandDescription{
+		Command:       "APPEND",
+		Name:          "Append",
+		Syntax:        "APPEND <key> <value>",
+		HelpText:      "Append a value to a key and return the new length",
+		Arity:         3,
+		Flags:         []CommandFlag{FlagWrite, FlagFast},
+		FirstKey:      1,
+		LastKey:       1,
+		KeyStep:       1,
+		ACLCategories: []string{"@string"},
+	}
+}
+
+type StrLenCommand struct {
+	server *Server
+}
+
+func (c *StrLenCommand) Execute(conn net.Conn, args []string) string {
+	value, _ := c.server.store.Get(args[0])
+	return strconv.Itoa(len(value))
+}
+
+func (c *StrLenCommand) Replay(args []string, store *database.KVStore) error {
+	return nil // No-op for replay
+}
+
+func (c *StrLenCommand) GetCommandInfo() CommandDescription {
+	return CommandDescription{
+		Command:       "STRLEN",
+		Name:          "String Length",
+		Syntax:        "STRLEN <key>",
+		HelpText:      "Get the length of the value stored at a key",
+		Arity:         2,
+		Flags:         []CommandFlag{FlagReadOnly, FlagFast},
+		FirstKey:      1,
+		LastKey:       1,
+		KeyStep:       1,
+		ACLCategories: []string{"@string"},
+	}
+}
+
+type GetRangeCommand struct {
+	server *Server
+}
+
+func (c *GetRangeCommand) Execute(conn net.Conn, args []string) string {
+	start, err := strconv.Atoi(args[1])
+	if err != nil {
+		return "ERR value is not an integer or out of range"
+	}
+	end, err := strconv.Atoi(args[2])
+	if err != nil {
+		return "ERR value is not an integer or out of range"
+	}
+
+	value, _ := c.server.store.Get(args[0])
+	from, to, ok := normalizeRange(start, end, len(value))
+	if !ok {
+		return ""
+	}
+	return value[from:to]
+}
+
+func (c *GetRangeCommand) Replay(args []string, store *database.KVStore) error {
+	return nil // No-op for replay
+}
+
+func (c *GetRangeCommand) GetCommandInfo() CommandDescription {
+	return CommandDescription{
+		Command:       "GETRANGE",
+		Name:          "Get Range",
+		Syntax:        "GETRANGE <key> <start> <end>",
+		HelpText:      "Get a substring of the value stored at a key, negative offsets count from the end",
+		Arity:         4,
+		Flags:         []CommandFlag{FlagReadOnly},
+		FirstKey:      1,
+		LastKey:       1,
+		KeyStep:       1,
+		ACLCategories: []string{"@string"},
+	}
+}
+
+type SetRangeCommand struct {
+	server *Server
+}
+
+func (c *SetRangeCommand) Execute(conn net.Conn, args []string) string {
+	key, value := args[0], args[2]
+	offset, err := strconv.Atoi(args[1])
+	if err != nil || offset < 0 {
+		return "ERR offset is out of range"
+	}
+	length, err := c.server.store.SetRange(key, offset, value)
+	if err != nil {
+		return fmt.Sprintf("ERR %v", err)
+	}
+	if value != "" {
+		c.server.appendToAOF("SETRANGE", key, args[1], value)
+	}
+	return strconv.Itoa(length)
+}
+
+func (c *SetRangeCommand) Replay(args []string, store *database.KVStore) error {
+	if len(args) != 3 {
+		return fmt.Errorf("invalid arguments for 'SETRANGE' command")
+	}
+	offset, err := strconv.Atoi(args[1])
+	if err != nil || offset < 0 {
+		return fmt.Errorf("invalid offset value: %s", args[1])
+	}
+	_, err = store.SetRange(args[0], offset, args[2])
+	return err
+}
+
+func (c *SetRangeCommand) GetCommandInfo() CommandDescription {
+	return CommandDescription{
+		Command:       "SETRANGE",
+		Name:          "Set Range",
+		Syntax:        "SETRANGE <key> <offset> <value>",
+		HelpText:      "Overwrite part of the value stored at a key starting at offset and return the new length",
+		Arity:         4,
+		Flags:         []CommandFlag{FlagWrite},
+		FirstKey:      1,
+		LastKey:       1,
+		KeyStep:       1,
+		ACLCategories: []string{"@string"},
+	}
+}
+
+type GetDelCommand struct {
+	server *Server
+}
+
+func (c *GetDelCommand) Execute(conn net.Conn, args []string) string {
+	key := args[0]
+	value, exists := c.server.store.GetDel(key)
+	if !exists {
+		return "nil"
+	}
+	c.server.appendToAOF("DEL", key)
+	return value
+}
+
+func (c *GetDelCommand) Replay(args []string, store *database.KVStore) error {
+	if len(args) != 1 {
+		return fmt.Errorf("invalid arguments for 'GETDEL' command")
+	}
+	store.Del(args[0])
+	return nil
+}
+
+func (c *GetDelCommand) GetCommandInfo() CommandDescription {
+	return CommandDescription{
+		Command:       "GETDEL",
+		Name:          "Get and Delete",
+		Syntax:        "GETDEL <key>",
+		HelpText:      "Get the value of a key and delete it",
+		Arity:         2,
+		Flags:         []CommandFlag{FlagWrite, FlagFast},
+		FirstKey:      1,
+		LastKey:       1,
+		KeyStep:       1,
+		ACLCategories: []string{"@string"},
+	}
+}
+
+type GetExCommand struct {
+	server *Server
+}
+
+// parseGetExOptions parses the optional arguments of GETEX.
+func parseGetExOptions(args []string) (time.Time, bool, error) {
+	switch {
+	case len(args) == 0:
+		return time.Time{}, false, nil
+	case len(args) == 1 && strings.ToUpper(args[0]) == "PERSIST":
+		return time.Time{}, true, nil
+	case len(args) == 2:
+		expireAt, err := parseExpiration(args[0], args[1])
+		return expireAt, false, err
+	}
+	return time.Time{}, false, errSyntax
+}
+
+func (c *GetExCommand) Execute(conn net.Conn, args []string) string {
+	key := args[0]
+	expireAt, persist, err := parseGetExOptions(args[1:])
+	if err != nil {
+		return fmt.Sprintf("ERR %v", err)
+	}
+
+	value, exists := c.server.store.GetEx(key, expireAt, persist)
+	if !exists {
+		return "nil"
+	}
+	if !expireAt.IsZero() {
+		c.server.appendToAOF("GETEX", key, "PXAT", strconv.FormatInt(expireAt.UnixMilli(), 10))
+	} else if persist {
+		c.server.appendToAOF("GETEX", key, "PERSIST")
+	}
+	return value
+}
+
+func (c *GetExCommand) Replay(args []string, store *database.KVStore) error {
+	if len(args) < 1 {
+		return fmt.Errorf("invalid arguments for 'GETEX' command")
+	}
+	expireAt, persist, err := parseGetExOptions(args[1:])
+	if err != nil {
+		return err
+	}
+	store.GetEx(args[0], expireAt, persist)
+	return nil
+}
+
+func (c *GetExCommand) GetCommandInfo() CommandDescription {
+	return CommandDescription{
+		Command:       "GETEX",
+		Name:          "Get and Expire",
+		Syntax:        "GETEX <key> [EX <seconds> | PX <milliseconds> | EXAT <unix-seconds> | PXAT <unix-milliseconds> | PERSIST]",
+		HelpText:      "Get the value of a key and optionally set or remove its expiration",
+		Arity:         -2,
+		Flags:         []CommandFlag{FlagWrite, FlagFast},
+		FirstKey:      1,
+		LastKey:       1,
+		KeyStep:       1,
+		ACLCategories: []string{"@string"},
+	}
+}
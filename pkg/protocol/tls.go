@@ -0,0 +1,227 @@
+package protocol
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/yashs662/SynchroDB/internal/config"
+	"github.com/yashs662/SynchroDB/internal/logger"
+)
+
+// certPollInterval is how often the certificate files are checked for
+// changes.
+const certPollInterval = 10 * time.Second
+
+// serverTLSConfig builds the TLS configuration of the listener. The
+// certificate is served by certificates so it can be replaced without a
+// restart. min_tls_version defaults to 1.2 and cipher_suites, which only apply
+// up to TLS 1.2, to Go's secure defaults. Client certificates are verified
+// against client_ca_file, and client_auth decides whether they are required
+// ("require"), verified only when presented ("optional") or not requested at
+// all ("none"). When client_auth is not set it defaults to "require" if a
+// client CA is configured and "none" otherwise.
+func serverTLSConfig(config *config.Config, certificates *certReloader) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		GetCertificate: certificates.GetCertificate,
+		NextProtos:     config.Server.ALPNProtocols,
+	}
+
+	var err error
+	tlsConfig.MinVersion, err = parseTLSVersion(config.Server.MinTLSVersion)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.CipherSuites, err = parseCipherSuites(config.Server.CipherSuites)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Server.ClientCAFile != "" {
+		tlsConfig.ClientCAs, err = loadCertPool(config.Server.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch config.Server.ClientAuth {
+	case "":
+		if tlsConfig.ClientCAs != nil {
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	case "none":
+		tlsConfig.ClientAuth = tls.NoClientCert
+	case "optional":
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	case "require":
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("invalid client_auth %q, expected none, optional or require", config.Server.ClientAuth)
+	}
+	if tlsConfig.ClientAuth != tls.NoClientCert && tlsConfig.ClientCAs == nil {
+		return nil, fmt.Errorf("client_ca_file is required to verify client certificates")
+	}
+	if config.Server.ClientCertUser && tlsConfig.ClientAuth == tls.NoClientCert {
+		return nil, fmt.Errorf("client_cert_user requires client certificates to be verified")
+	}
+	return tlsConfig, nil
+}
+
+// parseTLSVersion parses min_tls_version.
+func parseTLSVersion(version string) (uint16, error) {
+	switch version {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("invalid min_tls_version %q, expected 1.2 or 1.3", version)
+}
+
+// parseCipherSuites resolves cipher suite names such as
+// TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256. Suites Go considers insecure are
+// rejected.
+func parseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	known := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+	ids := make([]uint16, len(names))
+	for i, name := range names {
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+// certReloader serves the server certificate and reloads it when the files
+// change or Reload is called, so certificates can be rotated without
+// restarting the server.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload loads the certificate and key again. The current certificate is
+// kept when they cannot be loaded.
+func (r *certReloader) Reload() error {
+	modTime := r.latestModTime()
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificates: %w", err)
+	}
+	r.mu.Lock()
+	r.cert = &cert
+	r.modTime = modTime
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// latestModTime returns the newest modification time of the certificate and
+// key files.
+func (r *certReloader) latestModTime() time.Time {
+	var latest time.Time
+	for _, path := range []string{r.certFile, r.keyFile} {
+		if info, err := os.Stat(path); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+// watch reloads the certificate whenever its files change, checking every
+// interval until stop is closed.
+func (r *certReloader) watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		r.mu.RLock()
+		changed := r.latestModTime().After(r.modTime)
+		r.mu.RUnlock()
+		if !changed {
+			continue
+		}
+		if err := r.Reload(); err != nil {
+			// the files may be mid-rotation, try again on the next tick
+			logger.Warnf("Failed to reload changed TLS certificate: %v", err)
+			continue
+		}
+		logger.Infof("Reloaded TLS certificate from %s", r.certFile)
+	}
+}
+
+// ReloadCertificates loads the TLS certificate and key files again. New
+// connections use the new certificate, established ones are not affected.
+func (s *Server) ReloadCertificates() error {
+	certificates := s.certificates.Load()
+	if certificates == nil {
+		return fmt.Errorf("the server has not been started")
+	}
+	return certificates.Reload()
+}
+
+// loadCertPool reads a PEM bundle of CA certificates.
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in CA file %s", path)
+	}
+	return pool, nil
+}
+
+// authenticateClientCert authenticates conn as the ACL user named by the
+// common name of its verified client certificate, state being its TLS
+// connection state, when client_cert_user is enabled. Connections whose
+// certificate names no enabled user can still use AUTH.
+func (s *Server) authenticateClientCert(conn net.Conn, state tls.ConnectionState) {
+	if !s.clientCertUser {
+		return
+	}
+	if len(state.VerifiedChains) == 0 {
+		return
+	}
+	user := state.PeerCertificates[0].Subject.CommonName
+	if !s.acl.userEnabled(user) {
+		s.connLogger(conn).Warn("Client certificate names an unknown or disabled user", logger.User(user))
+		return
+	}
+	s.authenticateClient(conn, user)
+	s.connLogger(conn).Debug("Authenticated by client certificate", logger.User(user))
+}